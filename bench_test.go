@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ianlewis/runeio"
+)
+
+// benchInput returns an input of about 1MiB of short words.
+func benchInput() string {
+	line := "the quick brown fox jumps over the lazy dog 12345\n"
+	return strings.Repeat(line, (1<<20)/len(line))
+}
+
+// countWords counts the lexemes without building a tree so that the
+// benchmarks measure lexeme delivery.
+func countWords(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	for p.Next() != nil {
+	}
+	return nil, nil
+}
+
+func BenchmarkLexParse(b *testing.B) {
+	input := benchInput()
+
+	benchmarks := map[string][]Option{
+		"unbuffered":       nil,
+		"buffered":         {WithBufferSize(1024)},
+		"batched":          {WithBatchSize(DefaultBatchSize)},
+		"batched buffered": {WithBatchSize(DefaultBatchSize), WithBufferSize(16)},
+	}
+
+	for name, opts := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for range b.N {
+				r := runeio.NewReader(strings.NewReader(input))
+				if _, err := LexParse(context.Background(), r, &wordState{}, countWords, opts...); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	input := benchInput()
+	newReader := func(s string) BufferedRuneReader {
+		return runeio.NewReader(strings.NewReader(s))
+	}
+	newStringReader := func(s string) BufferedRuneReader {
+		return NewStringReader(s)
+	}
+
+	benchmarks := map[string]struct {
+		reader  func(string) BufferedRuneReader
+		opts    []Option
+		release bool
+	}{
+		"default":           {reader: newReader},
+		"released":          {reader: newReader, release: true},
+		"released interned": {reader: newReader, opts: []Option{WithInterner(NewInternTable(0))}, release: true},
+		"string reader":     {reader: newStringReader, release: true},
+	}
+
+	for name, bm := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for range b.N {
+				l := NewLexer(bm.reader(input), &wordState{}, bm.opts...)
+				for lexeme := range l.Lex(context.Background()) {
+					if bm.release {
+						lexeme.Release()
+					}
+				}
+				if err := l.Err(); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
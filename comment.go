@@ -0,0 +1,343 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrUnterminatedComment indicates that a block comment was not closed before
+// the end of the input.
+var ErrUnterminatedComment = errors.New("unterminated comment")
+
+// BlockComment defines the delimiters of a block comment.
+type BlockComment struct {
+	// Open is the opening delimiter (e.g. "/*").
+	Open string
+
+	// Close is the closing delimiter (e.g. "*/").
+	Close string
+
+	// Nested allows block comments to be nested. Each opening delimiter
+	// must then be matched by a closing delimiter.
+	Nested bool
+}
+
+// CommentSyntax defines the comment syntax of a language.
+type CommentSyntax struct {
+	// Line is a list of prefixes that start a comment that runs until the
+	// end of the line (e.g. "//", "#", "--"). The terminating newline is not
+	// consumed.
+	Line []string
+
+	// Block is a list of block comment delimiters.
+	Block []BlockComment
+
+	// DocLine is a list of prefixes that start a line doc comment (e.g.
+	// "///"). Doc comments are emitted as lexemes of type DocType rather
+	// than discarded if DocType is set. A prefix ending in a repeated rune
+	// isn't a doc comment if the rune is repeated again, e.g. "////" starts
+	// a plain comment.
+	DocLine []string
+
+	// DocBlock is a list of block doc comment delimiters (e.g. "/**", "*/").
+	// As with DocLine, "/***" starts a plain comment. An opening delimiter
+	// that forms the closing delimiter with the following input, e.g. the
+	// empty comment "/**/", is also a plain comment.
+	DocBlock []BlockComment
+
+	// DocType is the type of the lexemes emitted for doc comments. Doc
+	// comment lexemes include the comment delimiters. If DocType is zero,
+	// doc comments are discarded like other comments.
+	DocType LexemeType
+}
+
+var (
+	// CComments is the comment syntax for C, C++, Go, Java, and JavaScript.
+	CComments = CommentSyntax{
+		Line:  []string{"//"},
+		Block: []BlockComment{{Open: "/*", Close: "*/"}},
+	}
+
+	// ShellComments is the comment syntax for shell, Python, and many
+	// configuration languages.
+	ShellComments = CommentSyntax{
+		Line: []string{"#"},
+	}
+
+	// SQLComments is the comment syntax for SQL.
+	SQLComments = CommentSyntax{
+		Line:  []string{"--"},
+		Block: []BlockComment{{Open: "/*", Close: "*/"}},
+	}
+
+	// RustComments is the comment syntax for Rust. Doc comments are
+	// discarded unless DocType is set on a copy.
+	RustComments = CommentSyntax{
+		Line:    []string{"//"},
+		Block:   []BlockComment{{Open: "/*", Close: "*/", Nested: true}},
+		DocLine: []string{"///", "//!"},
+		DocBlock: []BlockComment{
+			{Open: "/**", Close: "*/", Nested: true},
+			{Open: "/*!", Close: "*/", Nested: true},
+		},
+	}
+
+	// HaskellComments is the comment syntax for Haskell.
+	HaskellComments = CommentSyntax{
+		Line:  []string{"--"},
+		Block: []BlockComment{{Open: "{-", Close: "-}", Nested: true}},
+	}
+
+	// OCamlComments is the comment syntax for OCaml.
+	OCamlComments = CommentSyntax{
+		Block: []BlockComment{{Open: "(*", Close: "*)", Nested: true}},
+	}
+)
+
+// commentMatch is a comment opening found in the input.
+type commentMatch struct {
+	open  string
+	block *BlockComment
+	doc   bool
+}
+
+// match returns the longest comment opening at the current position of l.
+func (c *CommentSyntax) match(l *Lexer) (commentMatch, bool, error) {
+	// NOTE: Doc comments are matched even if they are discarded so that the
+	//       longest opening delimiter is used, e.g. "/**" rather than "/*".
+	var maxLen int
+	for _, p := range c.Line {
+		maxLen = maxRuneLen(maxLen, p)
+	}
+	for _, p := range c.DocLine {
+		maxLen = maxRuneLen(maxLen, p)
+	}
+	for i := range c.Block {
+		maxLen = maxRuneLen(maxLen, c.Block[i].Open)
+	}
+	for i := range c.DocBlock {
+		maxLen = maxRuneLen(maxLen, c.DocBlock[i].Open)
+	}
+	if maxLen == 0 {
+		return commentMatch{}, false, nil
+	}
+
+	// NOTE: The input following doc comment openings is needed to check
+	//       whether they are plain comments.
+	peekLen := maxLen + 1
+	for i := range c.DocBlock {
+		b := &c.DocBlock[i]
+		peekLen = max(peekLen, utf8.RuneCountInString(b.Open)+utf8.RuneCountInString(b.Close))
+	}
+	rns, err := l.Peek(peekLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return commentMatch{}, false, fmt.Errorf("peeking input: %w", err)
+	}
+	in := string(rns)
+
+	var m commentMatch
+	var found bool
+	try := func(open string, block *BlockComment, doc bool) {
+		if open != "" && strings.HasPrefix(in, open) && len(open) > len(m.open) {
+			m = commentMatch{open: open, block: block, doc: doc && c.DocType != 0}
+			found = true
+		}
+	}
+	for _, p := range c.Line {
+		try(p, nil, false)
+	}
+	for _, p := range c.DocLine {
+		if !plainDoc(in, p, nil) {
+			try(p, nil, true)
+		}
+	}
+	for i := range c.Block {
+		try(c.Block[i].Open, &c.Block[i], false)
+	}
+	for i := range c.DocBlock {
+		if !plainDoc(in, c.DocBlock[i].Open, &c.DocBlock[i]) {
+			try(c.DocBlock[i].Open, &c.DocBlock[i], true)
+		}
+	}
+	return m, found, nil
+}
+
+// plainDoc reports whether the doc comment opening open at the start of in
+// starts a plain comment instead. This is the case if open ends in a
+// repeated rune that is repeated again, e.g. "////" or "/***", or if the end
+// of open and the following input form the closing delimiter of the block,
+// e.g. "/**/".
+func plainDoc(in, open string, block *BlockComment) bool {
+	if open == "" || !strings.HasPrefix(in, open) {
+		return false
+	}
+	rest := in[len(open):]
+	next, size := utf8.DecodeRuneInString(rest)
+	if size == 0 {
+		return false
+	}
+	last, lastSize := utf8.DecodeLastRuneInString(open)
+	prev, _ := utf8.DecodeLastRuneInString(open[:len(open)-lastSize])
+	if next == last && prev == last {
+		return true
+	}
+	return block != nil && strings.HasPrefix(open[len(open)-lastSize:]+rest, block.Close)
+}
+
+// SkipComment skips a single comment at the current position if there is one
+// and reports whether a comment was found. Doc comments are emitted as
+// lexemes of type c.DocType if it is set. SkipComment should be called at a
+// lexeme boundary as any pending lexeme value is discarded.
+//
+// If a block comment is not terminated, a *LexError wrapping
+// ErrUnterminatedComment is returned with the position of the opening
+// delimiter.
+func (l *Lexer) SkipComment(c CommentSyntax) (bool, error) {
+	m, ok, err := c.match(l)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	l.Ignore()
+	start := l.lexError(ErrUnterminatedComment)
+
+	// skip advances past n runes, keeping them in the lexeme only for doc
+	// comments.
+	skip := func(n int) error {
+		if m.doc {
+			_, err := l.Advance(n)
+			return err
+		}
+		_, err := l.Discard(n)
+		return err
+	}
+	// find searches for the tokens, keeping the text in the lexeme only for
+	// doc comments.
+	find := func(tokens []string) (string, error) {
+		if m.doc {
+			return l.Find(tokens)
+		}
+		return l.SkipTo(tokens)
+	}
+
+	if err := skip(utf8.RuneCountInString(m.open)); err != nil {
+		return true, err
+	}
+
+	if m.block == nil {
+		if _, err := find([]string{"\n"}); err != nil && !errors.Is(err, io.EOF) {
+			return true, err
+		}
+	} else {
+		tokens := []string{m.block.Close}
+		if m.block.Nested {
+			tokens = append(tokens, c.nestedOpens(m.block.Close)...)
+		}
+		for depth := 1; depth > 0; {
+			tok, err := find(tokens)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					return true, start
+				}
+				return true, err
+			}
+			if tok == m.block.Close {
+				depth--
+			} else {
+				depth++
+			}
+			if err := skip(utf8.RuneCountInString(tok)); err != nil {
+				return true, err
+			}
+		}
+	}
+
+	if m.doc {
+		l.Emit(l.Lexeme(c.DocType))
+	} else {
+		l.Ignore()
+	}
+	return true, nil
+}
+
+// SkipComments returns a State that skips any comments in the input before
+// running s. The returned State wraps the states returned by s so that
+// comments are skipped before every state. Comments are only skipped when
+// there is no pending lexeme value, so states that span several runs (e.g.
+// for string literals) should discard their opening delimiter before
+// returning.
+func SkipComments(c CommentSyntax, s State) State {
+	if s == nil {
+		return nil
+	}
+	return &commentState{c: c, s: s}
+}
+
+type commentState struct {
+	c CommentSyntax
+	s State
+}
+
+// Run implements State.
+func (cs *commentState) Run(ctx context.Context, l *Lexer) (State, error) {
+	if l.pending() == 0 {
+		for {
+			ok, err := l.SkipComment(cs.c)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+		}
+	}
+
+	next, err := cs.s.Run(ctx, l)
+	return SkipComments(cs.c, next), err
+}
+
+// nestedOpens returns the opening delimiters of the nested block comments,
+// including doc comments, that are closed by closeDelim. A nested comment
+// can contain any of them, e.g. a Rust doc comment can contain a "/*"
+// comment. The shortest delimiters are returned first so that "/**/" inside
+// a comment is skipped as "/*" followed by "*/".
+func (c *CommentSyntax) nestedOpens(closeDelim string) []string {
+	var opens []string
+	for _, blocks := range [][]BlockComment{c.Block, c.DocBlock} {
+		for _, b := range blocks {
+			if b.Nested && b.Close == closeDelim {
+				opens = append(opens, b.Open)
+			}
+		}
+	}
+	sort.SliceStable(opens, func(i, j int) bool {
+		return len(opens[i]) < len(opens[j])
+	})
+	return opens
+}
+
+func maxRuneLen(n int, s string) int {
+	if c := utf8.RuneCountInString(s); c > n {
+		return c
+	}
+	return n
+}
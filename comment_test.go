@@ -0,0 +1,210 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/ianlewis/runeio"
+)
+
+const docType LexemeType = 10
+
+// spaceWordState lexes space separated words.
+type spaceWordState struct{}
+
+func (w *spaceWordState) Run(_ context.Context, l *Lexer) (State, error) {
+	for {
+		rn, err := l.Peek(1)
+		if errors.Is(err, io.EOF) || (err == nil && unicode.IsSpace(rn[0])) {
+			if lexeme := l.Lexeme(wordType); lexeme.Value != "" {
+				l.Emit(lexeme)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if _, dErr := l.Discard(1); dErr != nil {
+				return nil, dErr
+			}
+			return w, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := l.Advance(1); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func lexAll(t *testing.T, input string, s State) ([]*Lexeme, error) {
+	t.Helper()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader(input)), s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lexemes []*Lexeme
+	for lexeme := range l.Lex(ctx) {
+		lexemes = append(lexemes, lexeme)
+	}
+	return lexemes, l.Err()
+}
+
+func TestSkipComments(t *testing.T) {
+	t.Parallel()
+
+	rustDoc := RustComments
+	rustDoc.DocType = docType
+
+	testCases := map[string]struct {
+		syntax CommentSyntax
+		input  string
+		want   []*Lexeme
+		err    *LexError
+	}{
+		"line": {
+			syntax: ShellComments,
+			input:  "a # b c\nd #",
+			want: []*Lexeme{
+				{Type: wordType, Value: "a"},
+				{Type: wordType, Value: "d", Pos: 8, Offset: 8, Line: 1},
+			},
+		},
+		"block": {
+			syntax: CComments,
+			input:  "a /* b\n */ c",
+			want: []*Lexeme{
+				{Type: wordType, Value: "a"},
+				{Type: wordType, Value: "c", Pos: 11, Offset: 11, Line: 1, Column: 4},
+			},
+		},
+		"not nested": {
+			syntax: CComments,
+			input:  "a /* x /* y */ z */",
+			want: []*Lexeme{
+				{Type: wordType, Value: "a"},
+				{Type: wordType, Value: "z", Pos: 15, Offset: 15, Column: 15},
+				{Type: wordType, Value: "*/", Pos: 17, Offset: 17, Column: 17},
+			},
+		},
+		"nested": {
+			syntax: RustComments,
+			input:  "a /* x /* y */ z */ b // c\nd",
+			want: []*Lexeme{
+				{Type: wordType, Value: "a"},
+				{Type: wordType, Value: "b", Pos: 20, Offset: 20, Column: 20},
+				{Type: wordType, Value: "d", Pos: 27, Offset: 27, Line: 1},
+			},
+		},
+		"haskell": {
+			syntax: HaskellComments,
+			input:  "{- a {- b -} -} c -- d",
+			want: []*Lexeme{
+				{Type: wordType, Value: "c", Pos: 16, Offset: 16, Column: 16},
+			},
+		},
+		"doc comments": {
+			syntax: rustDoc,
+			input:  "/// doc\n// line\n/** block */ a",
+			want: []*Lexeme{
+				{Type: docType, Value: "/// doc"},
+				{Type: docType, Value: "/** block */", Pos: 16, Offset: 16, Line: 2},
+				{Type: wordType, Value: "a", Pos: 29, Offset: 29, Line: 2, Column: 13},
+			},
+		},
+		"inner doc comments": {
+			syntax: rustDoc,
+			input:  "//! doc\n/*! a /* b */ */ c",
+			want: []*Lexeme{
+				{Type: docType, Value: "//! doc"},
+				{Type: docType, Value: "/*! a /* b */ */", Pos: 8, Offset: 8, Line: 1},
+				{Type: wordType, Value: "c", Pos: 25, Offset: 25, Line: 1, Column: 17},
+			},
+		},
+		"empty block not doc": {
+			syntax: rustDoc,
+			input:  "/**/x",
+			want: []*Lexeme{
+				{Type: wordType, Value: "x", Pos: 4, Offset: 4, Column: 4},
+			},
+		},
+		"repeated block marker not doc": {
+			syntax: rustDoc,
+			input:  "/***/x",
+			want: []*Lexeme{
+				{Type: wordType, Value: "x", Pos: 5, Offset: 5, Column: 5},
+			},
+		},
+		"repeated line marker not doc": {
+			syntax: rustDoc,
+			input:  "////x\ny",
+			want: []*Lexeme{
+				{Type: wordType, Value: "y", Pos: 6, Offset: 6, Line: 1},
+			},
+		},
+		"doc comments discarded": {
+			syntax: RustComments,
+			input:  "/// doc\n//! doc\n/** a */ b",
+			want: []*Lexeme{
+				{Type: wordType, Value: "b", Pos: 25, Offset: 25, Line: 2, Column: 9},
+			},
+		},
+		"unterminated": {
+			syntax: RustComments,
+			input:  "a /* b /* c */",
+			want: []*Lexeme{
+				{Type: wordType, Value: "a"},
+			},
+			err: &LexError{
+				Pos:    2,
+				Column: 2,
+				Err:    ErrUnterminatedComment,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := lexAll(t, tc.input, SkipComments(tc.syntax, &spaceWordState{}))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+			}
+			if tc.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var lexErr *LexError
+			if !errors.As(err, &lexErr) {
+				t.Fatalf("error is not a *LexError: %v", err)
+			}
+			if diff := cmp.Diff(*tc.err, *lexErr, cmpopts.EquateErrors()); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Clone returns a deep copy of the tree rooted at n. The copy of n has no
+// parent and the parent links of its descendants refer to the copied nodes.
+func (n *Node[V]) Clone() *Node[V] {
+	if n == nil {
+		return nil
+	}
+
+	type pair struct {
+		src, dst *Node[V]
+	}
+
+	root := n.shallowCopy()
+	stack := []pair{{n, root}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if top.src.Children == nil {
+			continue
+		}
+		top.dst.Children = make([]*Node[V], len(top.src.Children))
+		for i, c := range top.src.Children {
+			if c == nil {
+				continue
+			}
+			cc := c.shallowCopy()
+			cc.Parent = top.dst
+			top.dst.Children[i] = cc
+			stack = append(stack, pair{c, cc})
+		}
+	}
+	return root
+}
+
+// shallowCopy returns a copy of n without parent or children.
+func (n *Node[V]) shallowCopy() *Node[V] {
+	return &Node[V]{
+		Value:    n.Value,
+		Pos:      n.Pos,
+		Line:     n.Line,
+		Column:   n.Column,
+		Leading:  n.Leading,
+		Trailing: n.Trailing,
+		Source:   n.Source,
+	}
+}
+
+// EqualOptions configures the comparison of nodes by Equal and Diff.
+type EqualOptions struct {
+	// IgnorePositions ignores the Pos, Line and Column of nodes.
+	IgnorePositions bool
+
+	// IgnoreTrivia ignores the Leading and Trailing trivia of nodes.
+	IgnoreTrivia bool
+}
+
+// Equal reports whether the trees rooted at a and b are structurally equal.
+// Parent links are not compared.
+func Equal[V comparable](a, b *Node[V], opts EqualOptions) bool {
+	type pair struct {
+		a, b *Node[V]
+	}
+
+	stack := []pair{{a, b}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if top.a == nil || top.b == nil {
+			if top.a != top.b {
+				return false
+			}
+			continue
+		}
+		if !nodeEqual(top.a, top.b, opts) || len(top.a.Children) != len(top.b.Children) {
+			return false
+		}
+		for i := range top.a.Children {
+			stack = append(stack, pair{top.a.Children[i], top.b.Children[i]})
+		}
+	}
+	return true
+}
+
+// nodeEqual reports whether a and b are equal, ignoring their parents and
+// children.
+func nodeEqual[V comparable](a, b *Node[V], opts EqualOptions) bool {
+	if a.Value != b.Value {
+		return false
+	}
+	if !opts.IgnorePositions && (a.Pos != b.Pos || a.Line != b.Line || a.Column != b.Column) {
+		return false
+	}
+	if !opts.IgnoreTrivia && (a.Leading != b.Leading || a.Trailing != b.Trailing) {
+		return false
+	}
+	return true
+}
+
+// EditKind is the kind of an Edit.
+type EditKind int
+
+const (
+	// EditInsert is a node inserted in the new tree.
+	EditInsert EditKind = iota + 1
+
+	// EditDelete is a node deleted from the old tree.
+	EditDelete
+
+	// EditMove is a subtree moved to a different position.
+	EditMove
+
+	// EditChange is a node whose value, position or trivia changed.
+	EditChange
+)
+
+// String implements fmt.Stringer.
+func (k EditKind) String() string {
+	switch k {
+	case EditInsert:
+		return "insert"
+	case EditDelete:
+		return "delete"
+	case EditMove:
+		return "move"
+	case EditChange:
+		return "change"
+	default:
+		return fmt.Sprintf("EditKind(%d)", int(k))
+	}
+}
+
+// Path is the path to a node from the root of a tree given as the indexes of
+// the children along the way. The root's path is empty.
+type Path []int
+
+// String returns the path in the form /0/2/1.
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, i := range p {
+		b.WriteByte('/')
+		b.WriteString(strconv.Itoa(i))
+	}
+	return b.String()
+}
+
+// Edit is a single difference between two trees.
+type Edit[V comparable] struct {
+	// Kind is the kind of edit.
+	Kind EditKind
+
+	// OldPath is the path of Old in the old tree. It is nil for inserts.
+	OldPath Path
+
+	// NewPath is the path of New in the new tree. It is nil for deletes.
+	NewPath Path
+
+	// Old is the node in the old tree. It is nil for inserts.
+	Old *Node[V]
+
+	// New is the node in the new tree. It is nil for deletes.
+	New *Node[V]
+}
+
+// String returns a readable representation of the edit.
+func (e Edit[V]) String() string {
+	switch e.Kind {
+	case EditInsert:
+		return fmt.Sprintf("insert %s %v", e.NewPath, e.New.Value)
+	case EditDelete:
+		return fmt.Sprintf("delete %s %v", e.OldPath, e.Old.Value)
+	case EditMove:
+		return fmt.Sprintf("move %s -> %s %v", e.OldPath, e.NewPath, e.Old.Value)
+	default:
+		return fmt.Sprintf("%s %s %v -> %s %v", e.Kind, e.OldPath, e.Old.Value, e.NewPath, e.New.Value)
+	}
+}
+
+// Diff returns an edit script describing the differences between the trees
+// rooted at a and b. Children are aligned by the longest common subsequence
+// of equal subtrees. Remaining children with equal values, and then the
+// remaining children in the same position, are compared recursively. Any
+// others are reported as deleted or inserted. A deleted subtree that is equal
+// to an inserted subtree is reported as moved.
+//
+// Diff compares subtrees for each pair of children, so it is intended for
+// trees of moderate size such as configuration files.
+func Diff[V comparable](a, b *Node[V], opts EqualOptions) []Edit[V] {
+	d := differ[V]{opts: opts}
+	d.diff(a, b, Path{}, Path{})
+	d.moves()
+	return d.edits
+}
+
+type differ[V comparable] struct {
+	opts  EqualOptions
+	edits []Edit[V]
+}
+
+func (d *differ[V]) diff(a, b *Node[V], aPath, bPath Path) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		d.edits = append(d.edits, Edit[V]{Kind: EditInsert, NewPath: bPath, New: b})
+		return
+	case b == nil:
+		d.edits = append(d.edits, Edit[V]{Kind: EditDelete, OldPath: aPath, Old: a})
+		return
+	}
+
+	if !nodeEqual(a, b, d.opts) {
+		d.edits = append(d.edits, Edit[V]{
+			Kind:    EditChange,
+			OldPath: aPath,
+			NewPath: bPath,
+			Old:     a,
+			New:     b,
+		})
+	}
+	d.children(a, b, aPath, bPath)
+}
+
+func (d *differ[V]) children(a, b *Node[V], aPath, bPath Path) {
+	ac, bc := a.Children, b.Children
+
+	// lcs[i][j] is the length of the longest common subsequence of equal
+	// subtrees of ac[i:] and bc[j:].
+	eq := make([][]bool, len(ac))
+	lcs := make([][]int, len(ac)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bc)+1)
+	}
+	for i := range ac {
+		eq[i] = make([]bool, len(bc))
+		for j := range bc {
+			eq[i][j] = Equal(ac[i], bc[j], d.opts)
+		}
+	}
+	for i := len(ac) - 1; i >= 0; i-- {
+		for j := len(bc) - 1; j >= 0; j-- {
+			switch {
+			case eq[i][j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var gapA, gapB []int
+	flush := func() {
+		// Pair up children with equal values in order. The children that
+		// remain between two pairs are paired by position and any left over
+		// are deleted or inserted.
+		var pairs [][2]int
+		next := 0
+		for _, i := range gapA {
+			for k := next; k < len(gapB); k++ {
+				if j := gapB[k]; ac[i] != nil && bc[j] != nil && ac[i].Value == bc[j].Value {
+					pairs = append(pairs, [2]int{i, j})
+					next = k + 1
+					break
+				}
+			}
+		}
+		pairs = append(pairs, [2]int{len(ac), len(bc)})
+
+		gi, gj := 0, 0
+		for _, pr := range pairs {
+			var olds, news []int
+			for ; gi < len(gapA) && gapA[gi] < pr[0]; gi++ {
+				olds = append(olds, gapA[gi])
+			}
+			for ; gj < len(gapB) && gapB[gj] < pr[1]; gj++ {
+				news = append(news, gapB[gj])
+			}
+			for k := 0; k < len(olds) || k < len(news); k++ {
+				switch {
+				case k >= len(olds):
+					d.diff(nil, bc[news[k]], nil, childPath(bPath, news[k]))
+				case k >= len(news):
+					d.diff(ac[olds[k]], nil, childPath(aPath, olds[k]), nil)
+				default:
+					d.diff(ac[olds[k]], bc[news[k]], childPath(aPath, olds[k]), childPath(bPath, news[k]))
+				}
+			}
+			if pr[0] < len(ac) {
+				d.diff(ac[pr[0]], bc[pr[1]], childPath(aPath, pr[0]), childPath(bPath, pr[1]))
+				gi++
+				gj++
+			}
+		}
+		gapA, gapB = gapA[:0], gapB[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(ac) && j < len(bc) {
+		switch {
+		case eq[i][j] && lcs[i][j] == lcs[i+1][j+1]+1:
+			flush()
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			gapA = append(gapA, i)
+			i++
+		default:
+			gapB = append(gapB, j)
+			j++
+		}
+	}
+	for ; i < len(ac); i++ {
+		gapA = append(gapA, i)
+	}
+	for ; j < len(bc); j++ {
+		gapB = append(gapB, j)
+	}
+	flush()
+}
+
+// moves replaces pairs of deletes and inserts of equal subtrees with moves.
+// The move takes the place of the delete in the edit script.
+func (d *differ[V]) moves() {
+	removed := make([]bool, len(d.edits))
+	for i, e := range d.edits {
+		if e.Kind != EditDelete {
+			continue
+		}
+		for j, f := range d.edits {
+			if removed[j] || f.Kind != EditInsert || !Equal(e.Old, f.New, d.opts) {
+				continue
+			}
+			removed[j] = true
+			d.edits[i] = Edit[V]{
+				Kind:    EditMove,
+				OldPath: e.OldPath,
+				NewPath: f.NewPath,
+				Old:     e.Old,
+				New:     f.New,
+			}
+			break
+		}
+	}
+
+	edits := d.edits[:0]
+	for i, e := range d.edits {
+		if !removed[i] {
+			edits = append(edits, e)
+		}
+	}
+	d.edits = edits
+}
+
+// childPath returns a new path for child i of the node at p.
+func childPath(p Path, i int) Path {
+	c := make(Path, len(p)+1)
+	copy(c, p)
+	c[len(p)] = i
+	return c
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNode_Clone(t *testing.T) {
+	t.Parallel()
+
+	root := mutationTree()
+	root.Children[0].Pos = 3
+	root.Children[0].Leading = "# A\n"
+
+	c := root.Clone()
+	if !Equal(root, c, EqualOptions{}) {
+		t.Fatalf("clone not equal: want: %s, got: %s", shape(root), shape(c))
+	}
+	if c.Parent != nil {
+		t.Errorf("clone has parent: %v", c.Parent.Value)
+	}
+	checkParents(t, c)
+
+	// The clone shares no nodes with the original.
+	orig := map[*Node[string]]bool{}
+	for n := range PreOrder(root) {
+		orig[n] = true
+	}
+	for n := range PreOrder(c) {
+		if orig[n] {
+			t.Errorf("clone shares node %v", n.Value)
+		}
+	}
+
+	// Cloning a subtree detaches the copy.
+	if sub := find(root, "A").Clone(); sub.Parent != nil {
+		t.Errorf("subtree clone has parent: %v", sub.Parent.Value)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		a, b *Node[string]
+		opts EqualOptions
+		want bool
+	}{
+		"equal": {
+			a:    mutationTree(),
+			b:    mutationTree(),
+			want: true,
+		},
+		"nil": {
+			a:    nil,
+			b:    nil,
+			want: true,
+		},
+		"nil and non-nil": {
+			a:    nil,
+			b:    &Node[string]{},
+			want: false,
+		},
+		"value": {
+			a:    &Node[string]{Value: "A"},
+			b:    &Node[string]{Value: "B"},
+			want: false,
+		},
+		"children": {
+			a:    mutationTree(),
+			b:    addParent(&Node[string]{Value: "R", Children: []*Node[string]{{Value: "A"}}}),
+			want: false,
+		},
+		"position": {
+			a:    &Node[string]{Value: "A", Pos: 1, Line: 0, Column: 1},
+			b:    &Node[string]{Value: "A", Pos: 5, Line: 1, Column: 0},
+			want: false,
+		},
+		"ignore position": {
+			a:    &Node[string]{Value: "A", Pos: 1, Line: 0, Column: 1},
+			b:    &Node[string]{Value: "A", Pos: 5, Line: 1, Column: 0},
+			opts: EqualOptions{IgnorePositions: true},
+			want: true,
+		},
+		"trivia": {
+			a:    &Node[string]{Value: "A", Leading: " "},
+			b:    &Node[string]{Value: "A"},
+			want: false,
+		},
+		"ignore trivia": {
+			a:    &Node[string]{Value: "A", Leading: " "},
+			b:    &Node[string]{Value: "A"},
+			opts: EqualOptions{IgnoreTrivia: true},
+			want: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := Equal(tc.a, tc.b, tc.opts); got != tc.want {
+				t.Errorf("Equal: want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		op   func(r *Node[string]) *Node[string]
+		want []string
+	}{
+		"no change": {
+			op:   func(r *Node[string]) *Node[string] { return r },
+			want: nil,
+		},
+		"change": {
+			op: func(r *Node[string]) *Node[string] {
+				find(r, "E").Value = "F"
+				return r
+			},
+			want: []string{"change /0/1 E -> /0/1 F"},
+		},
+		"insert": {
+			op: func(r *Node[string]) *Node[string] {
+				_ = find(r, "B").InsertAfter(&Node[string]{Value: "F"})
+				return r
+			},
+			want: []string{"insert /2 F"},
+		},
+		"delete": {
+			op: func(r *Node[string]) *Node[string] {
+				_ = find(r, "D").Detach()
+				return r
+			},
+			want: []string{"delete /0/0 D"},
+		},
+		"move": {
+			op: func(r *Node[string]) *Node[string] {
+				_ = find(r, "C").MoveTo(find(r, "A"), 0)
+				return r
+			},
+			want: []string{"move /2 -> /0/0 C"},
+		},
+		"change subtree": {
+			op: func(r *Node[string]) *Node[string] {
+				find(r, "D").Value = "X"
+				_ = find(r, "B").Detach()
+				return r
+			},
+			want: []string{
+				"change /0/0 D -> /0/0 X",
+				"delete /1 B",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			old := mutationTree()
+			edits := Diff(old, tc.op(old.Clone()), EqualOptions{})
+
+			var got []string
+			for _, e := range edits {
+				got = append(got, e.String())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected edits (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
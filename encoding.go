@@ -0,0 +1,174 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"bytes"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidUTF16 is returned by a Reader that encounters invalid UTF-16
+// when using the ErrorInvalid policy.
+var ErrInvalidUTF16 = errors.New("invalid UTF-16")
+
+// Encoding is the character encoding of the input read by a Reader.
+type Encoding int
+
+const (
+	// DetectEncoding detects the encoding from the byte order mark (BOM) at
+	// the start of the input. Input without a BOM is decoded as UTF-8. It is
+	// the zero value so that BOMs are recognized by default.
+	DetectEncoding Encoding = iota
+
+	// UTF8 is the UTF-8 encoding.
+	UTF8
+
+	// UTF16LE is the little-endian UTF-16 encoding.
+	UTF16LE
+
+	// UTF16BE is the big-endian UTF-16 encoding.
+	UTF16BE
+
+	// Latin1 is the ISO-8859-1 encoding. Each byte is decoded as the rune
+	// with the same value so it can't be invalid.
+	Latin1
+)
+
+// String implements fmt.Stringer.
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "UTF-8"
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	case Latin1:
+		return "ISO-8859-1"
+	case DetectEncoding:
+		return "detect"
+	default:
+		return "unknown"
+	}
+}
+
+// The byte order marks of the Unicode encodings.
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// maxBOMLen is the length of the longest byte order mark.
+const maxBOMLen = 3
+
+// bom returns the byte order mark of the encoding.
+func (e Encoding) bom() []byte {
+	switch e {
+	case UTF8:
+		return bomUTF8
+	case UTF16LE:
+		return bomUTF16LE
+	case UTF16BE:
+		return bomUTF16BE
+	default:
+		return nil
+	}
+}
+
+// sniff returns the encoding given by the byte order mark at the start of b
+// and the length of the byte order mark. If e isn't DetectEncoding, only a
+// byte order mark for e is recognized.
+func (e Encoding) sniff(b []byte) (Encoding, int) {
+	if e != DetectEncoding {
+		if bom := e.bom(); bom != nil && bytes.HasPrefix(b, bom) {
+			return e, len(bom)
+		}
+		return e, 0
+	}
+	for _, enc := range []Encoding{UTF8, UTF16LE, UTF16BE} {
+		if bom := enc.bom(); bytes.HasPrefix(b, bom) {
+			return enc, len(bom)
+		}
+	}
+	return UTF8, 0
+}
+
+// width is the number of bytes used by most runes in the encoding. It is
+// used to map rune positions to byte offsets.
+func (e Encoding) width() int {
+	if e == UTF16LE || e == UTF16BE {
+		return 2
+	}
+	return 1
+}
+
+// invalid returns the error for invalid input in the encoding.
+func (e Encoding) invalid() error {
+	if e == UTF16LE || e == UTF16BE {
+		return ErrInvalidUTF16
+	}
+	return ErrInvalidUTF8
+}
+
+// decode decodes the first rune in b and returns it, its size in bytes and
+// whether it is valid. If b doesn't hold a complete rune and eof is false, it
+// returns a size of zero. An invalid rune is returned as utf8.RuneError.
+func (e Encoding) decode(b []byte, eof bool) (rune, int, bool) {
+	switch e {
+	case Latin1:
+		return rune(b[0]), 1, true
+	case UTF16LE, UTF16BE:
+		return e.decodeUTF16(b, eof)
+	default:
+		if !utf8.FullRune(b) && !eof {
+			return 0, 0, false
+		}
+		rn, size := utf8.DecodeRune(b)
+		return rn, size, rn != utf8.RuneError || size > 1
+	}
+}
+
+func (e Encoding) decodeUTF16(b []byte, eof bool) (rune, int, bool) {
+	unit := func(b []byte) rune {
+		if e == UTF16LE {
+			return rune(b[0]) | rune(b[1])<<8
+		}
+		return rune(b[0])<<8 | rune(b[1])
+	}
+
+	if len(b) < 2 {
+		if !eof {
+			return 0, 0, false
+		}
+		return utf8.RuneError, len(b), false
+	}
+	r1 := unit(b)
+	if !utf16.IsSurrogate(r1) {
+		return r1, 2, true
+	}
+	if len(b) < 4 {
+		if !eof {
+			return 0, 0, false
+		}
+		return utf8.RuneError, 2, false
+	}
+	if rn := utf16.DecodeRune(r1, unit(b[2:])); rn != utf8.RuneError {
+		return rn, 4, true
+	}
+	return utf8.RuneError, 2, false
+}
@@ -0,0 +1,235 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// encodeUTF16 returns s encoded as UTF-16 with the given byte order mark.
+func encodeUTF16(bom []byte, s string, le bool) []byte {
+	b := append([]byte{}, bom...)
+	for _, u := range utf16.Encode([]rune(s)) {
+		if le {
+			b = append(b, byte(u), byte(u>>8))
+		} else {
+			b = append(b, byte(u>>8), byte(u))
+		}
+	}
+	return b
+}
+
+func TestReader_encoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input []byte
+		opts  ReaderOptions
+		want  string
+		enc   Encoding
+		err   error
+	}{
+		"detect none": {
+			input: []byte("hé"),
+			opts:  ReaderOptions{Encoding: DetectEncoding},
+			want:  "hé",
+			enc:   UTF8,
+		},
+		"detect utf-8": {
+			input: []byte("\xef\xbb\xbfhé"),
+			opts:  ReaderOptions{Encoding: DetectEncoding},
+			want:  "hé",
+			enc:   UTF8,
+		},
+		"detect utf-16le": {
+			input: encodeUTF16(bomUTF16LE, "hé😀", true),
+			opts:  ReaderOptions{Encoding: DetectEncoding},
+			want:  "hé😀",
+			enc:   UTF16LE,
+		},
+		"detect utf-16be": {
+			input: encodeUTF16(bomUTF16BE, "hé😀", false),
+			opts:  ReaderOptions{Encoding: DetectEncoding},
+			want:  "hé😀",
+			enc:   UTF16BE,
+		},
+		"detect short": {
+			input: []byte("a"),
+			opts:  ReaderOptions{Encoding: DetectEncoding},
+			want:  "a",
+			enc:   UTF8,
+		},
+		"detect by default": {
+			input: encodeUTF16(bomUTF16LE, "hi", true),
+			want:  "hi",
+			enc:   UTF16LE,
+		},
+		"utf-8 bom": {
+			input: []byte("\xef\xbb\xbfhé"),
+			opts:  ReaderOptions{Encoding: UTF8},
+			want:  "hé",
+			enc:   UTF8,
+		},
+		"utf-8 other bom": {
+			input: []byte("\xff\xfea"),
+			opts:  ReaderOptions{Encoding: UTF8},
+			want:  "��a",
+			enc:   UTF8,
+		},
+		"utf-16le": {
+			input: encodeUTF16(nil, "hé😀", true),
+			opts:  ReaderOptions{Encoding: UTF16LE},
+			want:  "hé😀",
+			enc:   UTF16LE,
+		},
+		"utf-16be other bom": {
+			input: encodeUTF16(bomUTF16LE, "a", false),
+			opts:  ReaderOptions{Encoding: UTF16BE},
+			want:  "￾a",
+			enc:   UTF16BE,
+		},
+		"latin-1": {
+			input: []byte("caf\xe9"),
+			opts:  ReaderOptions{Encoding: Latin1},
+			want:  "café",
+			enc:   Latin1,
+		},
+		"utf-16 lenient": {
+			input: []byte{'a', 0, 0x00, 0xd8, 'b', 0, 'c'},
+			opts:  ReaderOptions{Encoding: UTF16LE},
+			want:  "a�b�",
+			enc:   UTF16LE,
+		},
+		"utf-16 strict": {
+			input: []byte{'a', 0, 0x00, 0xd8, 'b', 0},
+			opts:  ReaderOptions{Encoding: UTF16LE, Invalid: ErrorInvalid},
+			want:  "a",
+			enc:   UTF16LE,
+			err:   ErrInvalidUTF16,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewReader(bytes.NewReader(tc.input), tc.opts)
+			got, err := readAll(r)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected runes (-want +got):\n%s", diff)
+			}
+			if got, want := r.Encoding(), tc.enc; got != want {
+				t.Errorf("Encoding: want: %v, got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestReader_ByteOffset(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input []byte
+		opts  ReaderOptions
+		want  []int
+	}{
+		"utf-8": {
+			input: []byte("aé\xffb"),
+			opts:  ReaderOptions{ByteOffsets: true},
+			want:  []int{0, 1, 3, 4, 5},
+		},
+		"utf-8 skip": {
+			input: []byte("\xffa\xff\xffé"),
+			opts:  ReaderOptions{Invalid: SkipInvalid, ByteOffsets: true},
+			want:  []int{1, 4, 6},
+		},
+		"utf-16le": {
+			input: encodeUTF16(bomUTF16LE, "a😀\nb", true),
+			opts:  ReaderOptions{ByteOffsets: true},
+			want:  []int{2, 4, 8, 10, 12},
+		},
+		"disabled": {
+			input: []byte("aé"),
+			want:  []int{-1, -1},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := NewReader(bytes.NewReader(tc.input), tc.opts)
+			if _, err := readAll(r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int
+			for pos := range tc.want {
+				got = append(got, r.ByteOffset(pos))
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected offsets (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReader_strict(t *testing.T) {
+	t.Parallel()
+
+	r := NewReader(bytes.NewReader([]byte("Hello\nWo\xffrld!")), ReaderOptions{Invalid: ErrorInvalid})
+	_, err := LexParse(context.Background(), r, &wordState{}, parseWord)
+
+	var lexErr *LexError
+	if !errors.As(err, &lexErr) {
+		t.Fatalf("unexpected error: want: *LexError, got: %v", err)
+	}
+	if !errors.Is(err, ErrInvalidUTF8) {
+		t.Errorf("unexpected error: want: %v, got: %v", ErrInvalidUTF8, err)
+	}
+	if got, want := [3]int{lexErr.Pos, lexErr.Line, lexErr.Column}, [3]int{8, 1, 2}; got != want {
+		t.Errorf("Pos, Line, Column: want: %v, got: %v", want, got)
+	}
+}
+
+func TestLexParse_utf16(t *testing.T) {
+	t.Parallel()
+
+	input := "Hello Wörld!\n"
+	want, err := LexParseString(context.Background(), input, &wordState{}, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The encoding is detected by default.
+	r := bytes.NewReader(encodeUTF16(bomUTF16LE, input, true))
+	got, err := LexParseReader(context.Background(), r, &wordState{}, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+}
@@ -20,8 +20,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/ianlewis/runeio"
-
 	"github.com/ianlewis/lexparse"
 )
 
@@ -184,7 +182,7 @@ func execute(root *lexparse.Node[*tmplNode], data map[string]string) (string, er
 
 // ExampleLexParse implements a simple templating language.
 func ExampleLexParse() {
-	r := runeio.NewReader(strings.NewReader("Hello {{ subject }}!"))
+	r := lexparse.NewStringReader("Hello {{ subject }}!")
 	t, err := lexparse.LexParse(context.Background(), r, lexparse.StateFn(stateText), parseInit)
 	if err != nil {
 		panic(err)
@@ -0,0 +1,133 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphOptions configures WriteDOT and WriteMermaid.
+type GraphOptions[V comparable] struct {
+	// Label returns the label for a node value. If nil, values are
+	// formatted with fmt.Sprint.
+	Label func(V) string
+
+	// Positions annotates each node's label with its Line:Column. Lines and
+	// columns are shown one-indexed.
+	Positions bool
+
+	// Highlight reports whether a node should be highlighted, for example
+	// because it is an error node or the result of a query.
+	Highlight func(*Node[V]) bool
+}
+
+func (o GraphOptions[V]) label(n *Node[V]) string {
+	var s string
+	if o.Label != nil {
+		s = o.Label(n.Value)
+	} else {
+		s = fmt.Sprint(n.Value)
+	}
+	if o.Positions {
+		s += fmt.Sprintf("\n%d:%d", n.Line+1, n.Column+1)
+	}
+	return s
+}
+
+func (o GraphOptions[V]) highlight(n *Node[V]) bool {
+	return o.Highlight != nil && o.Highlight(n)
+}
+
+// graphIDs assigns an identifier to each node in the tree rooted at root in
+// pre-order and returns the nodes in that order.
+func graphIDs[V comparable](root *Node[V]) ([]*Node[V], map[*Node[V]]string) {
+	var nodes []*Node[V]
+	ids := map[*Node[V]]string{}
+	for n := range PreOrder(root) {
+		ids[n] = fmt.Sprintf("n%d", len(nodes))
+		nodes = append(nodes, n)
+	}
+	return nodes, ids
+}
+
+// WriteDOT writes the tree rooted at root to w in the Graphviz DOT language.
+func WriteDOT[V comparable](w io.Writer, root *Node[V], opts GraphOptions[V]) error {
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	b.WriteString("\tnode [shape=box];\n")
+
+	nodes, ids := graphIDs(root)
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "\t%s [label=%s", ids[n], dotQuote(opts.label(n)))
+		if opts.highlight(n) {
+			b.WriteString(", style=filled, fillcolor=\"#ff9966\"")
+		}
+		b.WriteString("];\n")
+	}
+	for _, n := range nodes {
+		for _, c := range n.Children {
+			if c != nil {
+				fmt.Fprintf(&b, "\t%s -> %s;\n", ids[n], ids[c])
+			}
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote returns s as a quoted DOT string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// WriteMermaid writes the tree rooted at root to w as a Mermaid flowchart.
+func WriteMermaid[V comparable](w io.Writer, root *Node[V], opts GraphOptions[V]) error {
+	var b strings.Builder
+	b.WriteString("graph TD\n")
+
+	nodes, ids := graphIDs(root)
+	var highlighted []string
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "\t%s[%s]\n", ids[n], mermaidQuote(opts.label(n)))
+		if opts.highlight(n) {
+			highlighted = append(highlighted, ids[n])
+		}
+	}
+	for _, n := range nodes {
+		for _, c := range n.Children {
+			if c != nil {
+				fmt.Fprintf(&b, "\t%s --> %s\n", ids[n], ids[c])
+			}
+		}
+	}
+	if len(highlighted) > 0 {
+		b.WriteString("\tclassDef highlight fill:#ff9966\n")
+		fmt.Fprintf(&b, "\tclass %s highlight\n", strings.Join(highlighted, ","))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidQuote returns s as a quoted Mermaid label.
+func mermaidQuote(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "\n", "<br>")
+	return `"` + r.Replace(s) + `"`
+}
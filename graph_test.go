@@ -0,0 +1,85 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// graphTree returns a small tree for the graph tests.
+func graphTree() *Node[string] {
+	return addParent(&Node[string]{
+		Value: "+",
+		Children: []*Node[string]{
+			{Value: "1", Pos: 2, Line: 0, Column: 2},
+			{Value: `"a"`, Pos: 6, Line: 1, Column: 0},
+		},
+	})
+}
+
+func TestWriteDOT(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	err := WriteDOT(&b, graphTree(), GraphOptions[string]{
+		Positions: true,
+		Highlight: func(n *Node[string]) bool { return n.Value == "1" },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `digraph {
+	node [shape=box];
+	n0 [label="+\n1:1"];
+	n1 [label="1\n1:3", style=filled, fillcolor="#ff9966"];
+	n2 [label="\"a\"\n2:1"];
+	n0 -> n1;
+	n0 -> n2;
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteMermaid(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	err := WriteMermaid(&b, graphTree(), GraphOptions[string]{
+		Label:     strings.ToUpper,
+		Highlight: func(n *Node[string]) bool { return n.Parent != nil },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `graph TD
+	n0["+"]
+	n1["1"]
+	n2["#quot;A#quot;"]
+	n0 --> n1
+	n0 --> n2
+	classDef highlight fill:#ff9966
+	class n1,n2 highlight
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+}
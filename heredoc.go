@@ -0,0 +1,313 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnterminatedString indicates that a string or heredoc was not
+	// terminated before the end of the input.
+	ErrUnterminatedString = errors.New("unterminated string")
+
+	// ErrInvalidDelimiter indicates that a heredoc or raw string delimiter
+	// is malformed.
+	ErrInvalidDelimiter = errors.New("invalid delimiter")
+)
+
+// maxCPPDelimiter is the maximum length of a C++ raw string delimiter.
+const maxCPPDelimiter = 16
+
+// Heredoc describes a heredoc whose body is scanned by ScanHeredocBody.
+type Heredoc struct {
+	// Delimiter is the delimiter that terminates the heredoc body.
+	Delimiter string
+
+	// Indented allows the terminating delimiter to be preceded by
+	// whitespace.
+	Indented bool
+
+	// StripIndent strips the common leading whitespace from the lines of the
+	// body.
+	StripIndent bool
+
+	// Quoted reports whether the delimiter was quoted (e.g. <<'EOF'). Many
+	// languages disable interpolation in quoted heredocs.
+	Quoted bool
+}
+
+// ScanHeredocHeader scans a heredoc header starting with prefix (e.g. "<<" or
+// "<<<") at the current position. The prefix may be followed by '-' or '~',
+// which sets both Indented and StripIndent, and a delimiter which may be
+// quoted with single or double quotes. The header is added to the current
+// lexeme value.
+//
+// The rest of the line following the header is left to the caller. The body
+// is scanned by calling ScanHeredocBody at the start of the following line.
+func (l *Lexer) ScanHeredocHeader(prefix string) (Heredoc, error) {
+	var h Heredoc
+
+	ok, err := l.accept(prefix)
+	if err != nil {
+		return h, err
+	}
+	if !ok {
+		return h, l.lexError(fmt.Errorf("%w: expected %q", ErrInvalidDelimiter, prefix))
+	}
+
+	rn, err := l.peekRune()
+	if err != nil {
+		return h, err
+	}
+	if rn == '-' || rn == '~' {
+		h.Indented = true
+		h.StripIndent = true
+		if _, err = l.Advance(1); err != nil {
+			return h, err
+		}
+		if rn, err = l.peekRune(); err != nil {
+			return h, err
+		}
+	}
+
+	var quote rune
+	if rn == '\'' || rn == '"' {
+		quote = rn
+		h.Quoted = true
+		if _, err = l.Advance(1); err != nil {
+			return h, err
+		}
+	}
+
+	var b strings.Builder
+	for {
+		if rn, err = l.peekRune(); err != nil {
+			return h, err
+		}
+		if rn != '_' && !unicode.IsLetter(rn) && !unicode.IsDigit(rn) {
+			break
+		}
+		b.WriteRune(rn)
+		if _, err = l.Advance(1); err != nil {
+			return h, err
+		}
+	}
+	if b.Len() == 0 {
+		return h, l.lexError(fmt.Errorf("%w: missing heredoc delimiter", ErrInvalidDelimiter))
+	}
+	h.Delimiter = b.String()
+
+	if quote != 0 {
+		if rn != quote {
+			return h, l.lexError(fmt.Errorf("%w: expected closing %q", ErrInvalidDelimiter, quote))
+		}
+		if _, err = l.Advance(1); err != nil {
+			return h, err
+		}
+	}
+
+	return h, nil
+}
+
+// ScanHeredocBody scans the body of a heredoc starting at the current
+// position up to and including the line consisting of h.Delimiter. The
+// newline following the delimiter is not consumed. The raw input is added to
+// the current lexeme value and the body, without the delimiter line, is
+// returned.
+//
+// If the delimiter is not found, a *LexError wrapping ErrUnterminatedString is
+// returned with the position of the start of the body.
+func (l *Lexer) ScanHeredocBody(h Heredoc) (string, error) {
+	start := l.lexError(ErrUnterminatedString)
+	offset := l.pending()
+
+	var lines []string
+	for {
+		_, err := l.Find([]string{"\n"})
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+
+		line := l.valueFrom(offset)
+		term := line
+		if h.Indented {
+			term = strings.TrimLeft(term, " \t")
+		}
+		if term == h.Delimiter {
+			break
+		}
+		if err != nil {
+			return "", start
+		}
+		lines = append(lines, line)
+
+		if _, err = l.Advance(1); err != nil {
+			return "", err
+		}
+		offset = l.pending()
+	}
+
+	if h.StripIndent {
+		stripIndent(lines)
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// stripIndent removes the longest common leading whitespace from lines.
+// Lines consisting only of whitespace are not considered.
+func stripIndent(lines []string) {
+	var indent string
+	first := true
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		ws := line[:len(line)-len(trimmed)]
+		if first {
+			indent = ws
+			first = false
+			continue
+		}
+		i := 0
+		for i < len(indent) && i < len(ws) && indent[i] == ws[i] {
+			i++
+		}
+		indent = indent[:i]
+	}
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, indent) {
+			lines[i] = line[len(indent):]
+		} else {
+			lines[i] = strings.TrimLeft(line, " \t")
+		}
+	}
+}
+
+// ScanFencedString scans a raw string literal consisting of prefix, any number
+// of fence runes, a quote, the contents, and a closing quote followed by the
+// same number of fence runes. For example, Rust raw strings (r#"..."#) can be
+// scanned with ScanFencedString("r", '#', '"'). The raw input is added to the
+// current lexeme value and the contents are returned.
+//
+// If the string is not terminated, a *LexError wrapping ErrUnterminatedString
+// is returned with the position of the start of the literal.
+func (l *Lexer) ScanFencedString(prefix string, fence, quote rune) (string, error) {
+	start := l.lexError(ErrUnterminatedString)
+
+	ok, err := l.accept(prefix)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", l.lexError(fmt.Errorf("%w: expected %q", ErrInvalidDelimiter, prefix))
+	}
+
+	var n int
+	for {
+		rn, pErr := l.peekRune()
+		if pErr != nil {
+			return "", pErr
+		}
+		if rn != fence {
+			if rn != quote {
+				return "", l.lexError(fmt.Errorf("%w: expected %q", ErrInvalidDelimiter, quote))
+			}
+			break
+		}
+		n++
+		if _, err = l.Advance(1); err != nil {
+			return "", err
+		}
+	}
+	if _, err = l.Advance(1); err != nil {
+		return "", err
+	}
+
+	return l.scanRawContents(string(quote)+strings.Repeat(string(fence), n), start)
+}
+
+// ScanCPPRawString scans a C++ style raw string literal (e.g.
+// R"delim(...)delim") at the current position. Encoding prefixes such as u8
+// must be consumed by the caller. The raw input is added to the current
+// lexeme value and the contents are returned.
+//
+// If the string is not terminated, a *LexError wrapping ErrUnterminatedString
+// is returned with the position of the start of the literal.
+func (l *Lexer) ScanCPPRawString() (string, error) {
+	start := l.lexError(ErrUnterminatedString)
+
+	ok, err := l.accept(`R"`)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", l.lexError(fmt.Errorf("%w: expected %q", ErrInvalidDelimiter, `R"`))
+	}
+
+	var b strings.Builder
+	for {
+		rn, pErr := l.peekRune()
+		if pErr != nil {
+			return "", pErr
+		}
+		if rn == '(' {
+			break
+		}
+		if rn == eof || rn == ')' || rn == '\\' || unicode.IsSpace(rn) || b.Len() >= maxCPPDelimiter {
+			return "", l.lexError(fmt.Errorf("%w: invalid raw string delimiter", ErrInvalidDelimiter))
+		}
+		b.WriteRune(rn)
+		if _, err = l.Advance(1); err != nil {
+			return "", err
+		}
+	}
+	if _, err = l.Advance(1); err != nil {
+		return "", err
+	}
+
+	return l.scanRawContents(")"+b.String()+`"`, start)
+}
+
+// scanRawContents scans up to and including term and returns the text prior to
+// term. startErr is returned if term is not found.
+func (l *Lexer) scanRawContents(term string, startErr error) (string, error) {
+	offset := l.pending()
+	if _, err := l.Find([]string{term}); err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", startErr
+		}
+		return "", err
+	}
+	contents := l.valueFrom(offset)
+
+	if _, err := l.Advance(utf8.RuneCountInString(term)); err != nil {
+		return "", err
+	}
+	return contents, nil
+}
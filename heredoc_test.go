@@ -0,0 +1,224 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+func TestLexer_ScanHeredoc(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input  string
+		header Heredoc
+		body   string
+		value  string
+		err    error
+	}{
+		"basic": {
+			input:  "<<EOF\nline 1\n  line 2\nEOF\nrest",
+			header: Heredoc{Delimiter: "EOF"},
+			body:   "line 1\n  line 2\n",
+			value:  "<<EOF\nline 1\n  line 2\nEOF",
+		},
+		"terminator at EOF": {
+			input:  "<<EOF\nbody\nEOF",
+			header: Heredoc{Delimiter: "EOF"},
+			body:   "body\n",
+			value:  "<<EOF\nbody\nEOF",
+		},
+		"terminator must start line": {
+			input:  "<<EOF\n  EOF\nEOF\n",
+			header: Heredoc{Delimiter: "EOF"},
+			body:   "  EOF\n",
+			value:  "<<EOF\n  EOF\nEOF",
+		},
+		"strip indent": {
+			input:  "<<-END\n    a\n\n      b\n  END\n",
+			header: Heredoc{Delimiter: "END", Indented: true, StripIndent: true},
+			body:   "a\n\n  b\n",
+			value:  "<<-END\n    a\n\n      b\n  END",
+		},
+		"quoted": {
+			input:  "<<~'SQL'\n\tSELECT 1;\n\tSQL\n",
+			header: Heredoc{Delimiter: "SQL", Indented: true, StripIndent: true, Quoted: true},
+			body:   "SELECT 1;\n",
+			value:  "<<~'SQL'\n\tSELECT 1;\n\tSQL",
+		},
+		"unterminated": {
+			input:  "<<EOF\nbody\nEO",
+			header: Heredoc{Delimiter: "EOF"},
+			err:    ErrUnterminatedString,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			table := NewInternTable(0)
+			l := NewLexer(runeio.NewReader(strings.NewReader(tc.input)), nil, WithInterner(table))
+			h, err := l.ScanHeredocHeader("<<")
+			if err != nil {
+				t.Fatalf("ScanHeredocHeader: unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.header, h); diff != "" {
+				t.Errorf("ScanHeredocHeader (-want +got):\n%s", diff)
+			}
+			if _, err = l.Advance(1); err != nil {
+				t.Fatalf("Advance: unexpected error: %v", err)
+			}
+
+			body, err := l.ScanHeredocBody(h)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("ScanHeredocBody: unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := body, tc.body; got != want {
+				t.Errorf("body: want: %q, got: %q", want, got)
+			}
+			if got, want := l.Lexeme(unusedType).Value, tc.value; got != want {
+				t.Errorf("lexeme.Value: want: %q, got: %q", want, got)
+			}
+
+			// Only the lexeme's value is interned.
+			if got, want := table.Len(), 1; got != want {
+				t.Errorf("interned values: want: %d, got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestLexer_ScanHeredocHeader_invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"<EOF", "<< EOF", "<<'EOF"} {
+		l := NewLexer(runeio.NewReader(strings.NewReader(input)), nil)
+		if _, err := l.ScanHeredocHeader("<<"); !errors.Is(err, ErrInvalidDelimiter) {
+			t.Errorf("ScanHeredocHeader(%q): unexpected error: %v", input, err)
+		}
+	}
+}
+
+func TestLexer_ScanRawString(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input    string
+		scan     func(*Lexer) (string, error)
+		contents string
+		value    string
+		err      error
+		col      int
+	}{
+		"rust no fence": {
+			input: `r"a\b" x`,
+			scan: func(l *Lexer) (string, error) {
+				return l.ScanFencedString("r", '#', '"')
+			},
+			contents: `a\b`,
+			value:    `r"a\b"`,
+		},
+		"rust fenced": {
+			input: `r##"a "# b"## x`,
+			scan: func(l *Lexer) (string, error) {
+				return l.ScanFencedString("r", '#', '"')
+			},
+			contents: `a "# b`,
+			value:    `r##"a "# b"##`,
+		},
+		"rust unterminated": {
+			input: `  r#"a"`,
+			scan: func(l *Lexer) (string, error) {
+				if _, err := l.Discard(2); err != nil {
+					return "", err
+				}
+				return l.ScanFencedString("r", '#', '"')
+			},
+			err: ErrUnterminatedString,
+			col: 2,
+		},
+		"rust missing quote": {
+			input: `r#a`,
+			scan: func(l *Lexer) (string, error) {
+				return l.ScanFencedString("r", '#', '"')
+			},
+			err: ErrInvalidDelimiter,
+			col: 2,
+		},
+		"cpp": {
+			input:    `R"x(a)" b)x" c`,
+			scan:     (*Lexer).ScanCPPRawString,
+			contents: `a)" b`,
+			value:    `R"x(a)" b)x"`,
+		},
+		"cpp empty delimiter": {
+			input:    `R"(a\nb)"`,
+			scan:     (*Lexer).ScanCPPRawString,
+			contents: `a\nb`,
+			value:    `R"(a\nb)"`,
+		},
+		"cpp invalid delimiter": {
+			input: `R"a b(x)a b"`,
+			scan:  (*Lexer).ScanCPPRawString,
+			err:   ErrInvalidDelimiter,
+			col:   3,
+		},
+		"cpp unterminated": {
+			input: `R"x(a)"`,
+			scan:  (*Lexer).ScanCPPRawString,
+			err:   ErrUnterminatedString,
+			col:   0,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := NewLexer(runeio.NewReader(strings.NewReader(tc.input)), nil)
+			contents, err := tc.scan(l)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				var lexErr *LexError
+				if !errors.As(err, &lexErr) {
+					t.Fatalf("error is not a *LexError: %v", err)
+				}
+				if got, want := lexErr.Column, tc.col; got != want {
+					t.Errorf("LexError.Column: want: %v, got: %v", want, got)
+				}
+				return
+			}
+			if got, want := contents, tc.contents; got != want {
+				t.Errorf("contents: want: %q, got: %q", want, got)
+			}
+			if got, want := l.Lexeme(unusedType).Value, tc.value; got != want {
+				t.Errorf("lexeme.Value: want: %q, got: %q", want, got)
+			}
+		})
+	}
+}
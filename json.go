@@ -0,0 +1,187 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Codec encodes and decodes node values when serializing trees.
+type Codec[V comparable] interface {
+	// Encode returns the JSON encoding of v.
+	Encode(v V) ([]byte, error)
+
+	// Decode returns the value encoded in data.
+	Decode(data []byte) (V, error)
+}
+
+// CodecFuncs implements Codec using functions.
+type CodecFuncs[V comparable] struct {
+	EncodeFunc func(v V) ([]byte, error)
+	DecodeFunc func(data []byte) (V, error)
+}
+
+// Encode implements Codec.
+func (c CodecFuncs[V]) Encode(v V) ([]byte, error) {
+	return c.EncodeFunc(v)
+}
+
+// Decode implements Codec.
+func (c CodecFuncs[V]) Decode(data []byte) (V, error) {
+	return c.DecodeFunc(data)
+}
+
+// JSONCodec is a Codec that uses encoding/json. If V is a pointer type, a new
+// value is allocated when decoding.
+type JSONCodec[V comparable] struct{}
+
+// Encode implements Codec.
+func (JSONCodec[V]) Encode(v V) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode implements Codec.
+func (JSONCodec[V]) Decode(data []byte) (V, error) {
+	var v V
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// jsonNode is the serialized form of a Node.
+type jsonNode struct {
+	Value    json.RawMessage `json:"value"`
+	Pos      int             `json:"pos"`
+	Line     int             `json:"line"`
+	Column   int             `json:"column"`
+	Source   string          `json:"source,omitempty"`
+	Leading  string          `json:"leading,omitempty"`
+	Trailing string          `json:"trailing,omitempty"`
+	Children []*jsonNode     `json:"children,omitempty"`
+}
+
+// MarshalTree returns the JSON encoding of the tree rooted at n. Node values
+// are encoded using c. The Parent links are not encoded and each node's Source
+// is encoded by its name.
+func MarshalTree[V comparable](n *Node[V], c Codec[V]) ([]byte, error) {
+	jn, err := toJSONNode(n, c, Path{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jn)
+}
+
+func toJSONNode[V comparable](n *Node[V], c Codec[V], path Path) (*jsonNode, error) {
+	if n == nil {
+		return nil, nil
+	}
+	v, err := c.Encode(n.Value)
+	if err != nil {
+		return nil, fmt.Errorf("encoding value at %s: %w", path, err)
+	}
+	jn := &jsonNode{
+		Value:    v,
+		Pos:      n.Pos,
+		Line:     n.Line,
+		Column:   n.Column,
+		Source:   n.Source.Name(),
+		Leading:  n.Leading,
+		Trailing: n.Trailing,
+	}
+	for i, child := range n.Children {
+		jc, err := toJSONNode(child, c, childPath(path, i))
+		if err != nil {
+			return nil, err
+		}
+		jn.Children = append(jn.Children, jc)
+	}
+	return jn, nil
+}
+
+// UnmarshalTree decodes a tree encoded by MarshalTree. Node values are decoded
+// using c and the Parent links are rebuilt. Nodes encoded with the same Source
+// name share a new Source with that name. The Sources don't belong to a
+// FileSet and don't have line information.
+func UnmarshalTree[V comparable](data []byte, c Codec[V]) (*Node[V], error) {
+	var jn *jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return nil, err
+	}
+	return fromJSONNode(jn, c, nil, Path{}, map[string]*Source{})
+}
+
+func fromJSONNode[V comparable](
+	jn *jsonNode,
+	c Codec[V],
+	parent *Node[V],
+	path Path,
+	sources map[string]*Source,
+) (*Node[V], error) {
+	if jn == nil {
+		return nil, nil
+	}
+	v, err := c.Decode(jn.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding value at %s: %w", path, err)
+	}
+	n := &Node[V]{
+		Parent:   parent,
+		Value:    v,
+		Pos:      jn.Pos,
+		Line:     jn.Line,
+		Column:   jn.Column,
+		Leading:  jn.Leading,
+		Trailing: jn.Trailing,
+	}
+	if jn.Source != "" {
+		if sources[jn.Source] == nil {
+			sources[jn.Source] = &Source{name: jn.Source}
+		}
+		n.Source = sources[jn.Source]
+	}
+	for i, jc := range jn.Children {
+		child, err := fromJSONNode(jc, c, n, childPath(path, i), sources)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, child)
+	}
+	return n, nil
+}
+
+// MarshalJSON implements json.Marshaler. Values are encoded using JSONCodec.
+func (n *Node[V]) MarshalJSON() ([]byte, error) {
+	return MarshalTree(n, JSONCodec[V]{})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Values are decoded using
+// JSONCodec. The decoded node has no parent.
+func (n *Node[V]) UnmarshalJSON(data []byte) error {
+	d, err := UnmarshalTree(data, JSONCodec[V]{})
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		*n = Node[V]{}
+		return nil
+	}
+	*n = *d
+	for _, c := range n.Children {
+		if c != nil {
+			c.Parent = n
+		}
+	}
+	return nil
+}
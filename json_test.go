@@ -0,0 +1,129 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMarshalTree(t *testing.T) {
+	t.Parallel()
+
+	root := mutationTree()
+	a := find(root, "A")
+	a.Pos, a.Line, a.Column = 4, 1, 2
+	a.Leading = "# comment\n"
+	src := NewFileSet().AddSource("a.txt")
+	a.Source = src
+	find(root, "E").Source = src
+
+	data, err := MarshalTree(root, JSONCodec[string]{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := UnmarshalTree(data, JSONCodec[string]{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !Equal(root, got, EqualOptions{}) {
+		t.Errorf("unexpected tree: want: %s, got: %s", shape(root), shape(got))
+	}
+	if got.Parent != nil {
+		t.Errorf("root has parent: %v", got.Parent.Value)
+	}
+	checkParents(t, got)
+
+	// Nodes from the same source share a Source.
+	gotA, gotE := find(got, "A"), find(got, "E")
+	if gotA.Source.Name() != "a.txt" || gotE.Source != gotA.Source {
+		t.Errorf("Source: want: shared %q, got: %q, %q", "a.txt", gotA.Source.Name(), gotE.Source.Name())
+	}
+	if got.Source != nil {
+		t.Errorf("Source: want: nil, got: %q", got.Source.Name())
+	}
+}
+
+type jsonValue struct {
+	Name string `json:"name"`
+}
+
+func TestNode_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	root := addParent(&Node[*jsonValue]{
+		Value: &jsonValue{Name: "root"},
+		Children: []*Node[*jsonValue]{
+			{Value: &jsonValue{Name: "child"}, Pos: 1, Column: 1},
+		},
+	})
+
+	data, err := json.Marshal(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got Node[*jsonValue]
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := got.Value.Name, "root"; got != want {
+		t.Errorf("Value: want: %q, got: %q", want, got)
+	}
+	if len(got.Children) != 1 {
+		t.Fatalf("Children: want: 1, got: %d", len(got.Children))
+	}
+	c := got.Children[0]
+	if c.Value.Name != "child" || c.Pos != 1 || c.Column != 1 {
+		t.Errorf("unexpected child: %+v", c)
+	}
+	if c.Parent != &got {
+		t.Errorf("child parent not set")
+	}
+}
+
+func TestUnmarshalTree_codecError(t *testing.T) {
+	t.Parallel()
+
+	errBad := errors.New("bad value")
+	codec := CodecFuncs[string]{
+		EncodeFunc: func(v string) ([]byte, error) {
+			return json.Marshal(v)
+		},
+		DecodeFunc: func(data []byte) (string, error) {
+			if string(data) == `"B"` {
+				return "", errBad
+			}
+			var s string
+			err := json.Unmarshal(data, &s)
+			return s, err
+		},
+	}
+
+	data, err := MarshalTree(mutationTree(), codec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = UnmarshalTree(data, codec)
+	if !errors.Is(err, errBad) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(err.Error(), "/1") {
+		t.Errorf("error does not contain path: %v", err)
+	}
+}
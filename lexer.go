@@ -21,6 +21,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"unicode/utf8"
 )
 
 // BufferedRuneReader implements functionality that allows for allow for zero-copy
@@ -46,6 +47,35 @@ type BufferedRuneReader interface {
 	Discard(n int) (int, error)
 }
 
+// LexError is an error encountered while lexing. It records the position in
+// the input where the error occurred.
+type LexError struct {
+	// Pos is the position in the input where the error occurred.
+	Pos int
+
+	// Line is the line number in the input where the error occurred.
+	Line int
+
+	// Column is the column in the line where the error occurred.
+	Column int
+
+	// Filename is the name of the Source being lexed, if any.
+	Filename string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *LexError) Error() string {
+	return fmt.Sprintf("%s: %v", positionString(e.Filename, e.Line, e.Column), e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LexError) Unwrap() error {
+	return e.Err
+}
+
 // LexemeType is a user-defined Lexeme type.
 type LexemeType int
 
@@ -85,28 +115,77 @@ type Lexeme struct {
 	// Pos is the position in the byte stream where the Lexeme was found.
 	Pos int
 
+	// Offset is the byte offset in the input where the Lexeme was found. If
+	// the Lexer read from a SliceSource, the Value is the input between
+	// Offset and Offset+len(Value). For other readers it is the offset in the
+	// UTF-8 encoding of the runes read, which is the same if the input is
+	// valid UTF-8.
+	Offset int
+
 	// Line is the line number where the Lexeme was found.
 	Line int
 
 	// Column is the column in the line where the Lexeme was found.
 	Column int
+
+	// Leading is the trivia (e.g. whitespace and comments) preceding the
+	// Lexeme. It is only set if trivia is preserved by the Lexer.
+	Leading string
+
+	// Trailing is the trivia following the Lexeme up to and including the
+	// next newline. It is only set if trivia is preserved by the Lexer.
+	Trailing string
+
+	// Source is the source the Lexeme was read from. It is only set if the
+	// Lexer was given a Source.
+	Source *Source
 }
 
+// TriviaType is the type of the Lexeme emitted at the end of the input when
+// trivia is preserved and the input consisted only of trivia.
+const TriviaType LexemeType = -1
+
 // Lexer lexically processes a byte stream. It is implemented as a finite-state
 // machine in which each State implements it's own processing.
 type Lexer struct {
 	// lexemes is a channel into which Lexeme's will be emitted.
 	lexemes chan *Lexeme
 
+	// batches is a channel into which batches of Lexemes will be emitted
+	// when the lexer was started by LexBatches.
+	batches chan []*Lexeme
+
+	// batched indicates that the lexer was started by LexBatches.
+	batched bool
+
+	// batch holds the lexemes not yet sent on the batches channel.
+	batch []*Lexeme
+
+	// bufferSize is the buffer size of the lexemes and batches channels.
+	bufferSize int
+
+	// batchSize is the number of lexemes sent in each batch.
+	batchSize int
+
 	// stop is the stop channel
 	stop chan struct{}
 
 	// done is the done channel
 	done chan struct{}
 
+	// stopOnce ensures that the stop channel is closed only once.
+	stopOnce sync.Once
+
+	// wg tracks the goroutines started by Lex.
+	wg sync.WaitGroup
+
 	// state is the current state of the Lexer.
 	state State
 
+	// held is the last emitted lexeme which is held back until its trailing
+	// trivia is known. It is only used when trivia is preserved.
+	held *Lexeme
+
 	// s is the current input/pos/lexeme state.
 	s struct {
 		// Mutex protects the values in s.
@@ -115,8 +194,20 @@ type Lexer struct {
 		// r is the underlying reader to read from.
 		r BufferedRuneReader
 
-		// b is a strings builder that stores the current lexeme value.
-		b strings.Builder
+		// slice is r if it is a SliceSource. The current lexeme value is
+		// then sliced from the input rather than copied to b.
+		slice SliceSource
+
+		// b stores the current lexeme value. Its storage is reused for
+		// each lexeme. It is not used if slice is set.
+		b []byte
+
+		// offset is the byte offset of the next rune if slice is not set.
+		// It counts the UTF-8 encoded length of the runes read.
+		offset int
+
+		// startOffset is the byte offset of the current lexeme.
+		startOffset int
 
 		// pos is the current position in the input stream.
 		pos int
@@ -138,21 +229,115 @@ type Lexer struct {
 
 		// err holds the last lexing error.
 		err error
+
+		// keepTrivia indicates that discarded input should be preserved as
+		// trivia.
+		keepTrivia bool
+
+		// trivia holds input discarded since the last emitted lexeme.
+		trivia strings.Builder
+
+		// started indicates that Lex or Close has been called.
+		started bool
+
+		// recoverPanics indicates that panics in states are recovered and
+		// returned as a *PanicError.
+		recoverPanics bool
+
+		// limits are the resource limits enforced by the lexer.
+		limits Limits
+
+		// emitted is the number of lexemes emitted.
+		emitted int
+
+		// interner is used to intern lexeme values if not nil.
+		interner Interner
+
+		// source is the source being lexed if not nil.
+		source *Source
 	}
 }
 
 // NewLexer creates a new Lexer initialized with the given starting state.
-func NewLexer(r BufferedRuneReader, startingState State) *Lexer {
+func NewLexer(r BufferedRuneReader, startingState State, opts ...Option) *Lexer {
+	o := newOptions(opts)
 	l := &Lexer{
-		state:   startingState,
-		lexemes: make(chan *Lexeme),
-		stop:    make(chan struct{}),
-		done:    make(chan struct{}),
+		state:      startingState,
+		lexemes:    make(chan *Lexeme, o.bufferSize),
+		batches:    make(chan []*Lexeme, o.bufferSize),
+		bufferSize: o.bufferSize,
+		batchSize:  o.batchSize,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if l.batchSize <= 0 {
+		l.batchSize = DefaultBatchSize
 	}
 	l.s.r = r
+	l.s.slice, _ = r.(SliceSource)
+	if l.s.slice != nil {
+		l.s.startOffset = l.s.slice.Offset()
+	}
+	l.s.keepTrivia = o.trivia
+	l.s.recoverPanics = o.recoverPanics
+	l.s.limits = o.limits
+	l.s.interner = o.interner
+	l.s.source = o.source
 	return l
 }
 
+// PreserveTrivia sets whether input discarded by Discard, SkipTo and Ignore
+// is preserved. Preserved input is attached to the emitted lexemes as Leading
+// and Trailing trivia so that the concatenation of each lexeme's Leading,
+// Value and Trailing reproduces the input. Trailing trivia extends up to and
+// including the next newline and the rest becomes the Leading trivia of the
+// next lexeme.
+//
+// When trivia is preserved each lexeme is held back by the Lexer until the
+// next lexeme is emitted or lexing finishes. PreserveTrivia must be called
+// before Lex.
+func (l *Lexer) PreserveTrivia(keep bool) {
+	l.s.Lock()
+	l.s.keepTrivia = keep
+	l.s.Unlock()
+}
+
+// RecoverPanics sets whether a panic in a State is recovered. A recovered
+// panic stops the lexer and is returned by Err as a *PanicError. It must be
+// called before Lex.
+func (l *Lexer) RecoverPanics(recoverPanics bool) {
+	l.s.Lock()
+	l.s.recoverPanics = recoverPanics
+	l.s.Unlock()
+}
+
+// SetInterner sets the Interner used to intern the values of lexemes
+// returned by Lexeme. If i is nil, which is the default, each value is a
+// newly allocated string. Values sliced from a SliceSource are not interned.
+// SetInterner must be called before Lex.
+func (l *Lexer) SetInterner(i Interner) {
+	l.s.Lock()
+	l.s.interner = i
+	l.s.Unlock()
+}
+
+// SetSource sets the Source being lexed. The Lexer records the start of each
+// line in the Source, sets it as the Source of emitted lexemes and includes
+// its name in errors. SetSource must be called before Lex.
+func (l *Lexer) SetSource(src *Source) {
+	l.s.Lock()
+	l.s.source = src
+	l.s.Unlock()
+}
+
+// SetLimits sets the resource limits enforced by the Lexer. The Lexer stops
+// with a *LimitError when a limit is exceeded. It must be called before Lex.
+func (l *Lexer) SetLimits(limits Limits) {
+	l.s.Lock()
+	l.s.limits = limits
+	l.s.Unlock()
+}
+
 // Pos returns the current position of the underlying reader.
 func (l *Lexer) Pos() int {
 	l.s.Lock()
@@ -180,9 +365,8 @@ func (l *Lexer) Column() int {
 // ReadRune returns the next rune of input.
 func (l *Lexer) ReadRune() (rune, int, error) {
 	l.s.Lock()
-	rn, i, err := l.readrune()
-	l.s.Unlock()
-	return rn, i, err
+	defer l.s.Unlock()
+	return l.readrune()
 }
 
 func (l *Lexer) readrune() (rune, int, error) {
@@ -195,12 +379,52 @@ func (l *Lexer) readrune() (rune, int, error) {
 	l.s.pos++
 	l.s.column++
 	if rn == '\n' {
-		l.s.line++
-		l.s.column = 0
+		l.newline(l.s.pos)
+	}
+
+	if l.s.slice == nil {
+		l.s.offset += runeLen(rn)
+		l.s.b = utf8.AppendRune(l.s.b, rn)
+	}
+	return rn, n, l.checkLimits(true)
+}
+
+// checkLimits returns a *LimitError if the input has exceeded
+// Limits.MaxInputRunes or, if value is true, the current lexeme value has
+// exceeded Limits.MaxLexemeLength. The lock must be held.
+func (l *Lexer) checkLimits(value bool) error {
+	if m := l.s.limits.MaxInputRunes; m > 0 && l.s.pos > m {
+		return l.limitError(LimitInputRunes, int64(m))
+	}
+	if m := l.s.limits.MaxLexemeLength; value && m > 0 && l.s.pos-l.s.startPos > m {
+		return l.limitError(LimitLexemeLength, int64(m))
+	}
+	return nil
+}
+
+// limitError returns a new LimitError at the current position. The lock must
+// be held.
+func (l *Lexer) limitError(limit Limit, maxValue int64) *LimitError {
+	return &LimitError{
+		Limit:  limit,
+		Max:    maxValue,
+		Pos:    l.s.pos,
+		Line:   l.s.line,
+		Column: l.s.column,
 	}
+}
 
-	_, _ = l.s.b.WriteRune(rn)
-	return rn, n, nil
+// lexError returns a new LexError for err at the current position.
+func (l *Lexer) lexError(err error) *LexError {
+	l.s.Lock()
+	e := &LexError{
+		Pos:    l.s.pos,
+		Line:   l.s.line,
+		Column: l.s.column,
+		Err:    err,
+	}
+	l.s.Unlock()
+	return e
 }
 
 // Peek returns the next n runes from the buffer without advancing the
@@ -209,10 +433,42 @@ func (l *Lexer) readrune() (rune, int, error) {
 // indicating why the read is short.
 func (l *Lexer) Peek(n int) ([]rune, error) {
 	l.s.Lock()
-	p, err := l.s.r.Peek(n)
-	l.s.Unlock()
+	defer l.s.Unlock()
 	//nolint:wrapcheck // Error doesn't need to be wrapped.
-	return p, err
+	return l.s.r.Peek(n)
+}
+
+// eof is returned by peekRune at the end of the input.
+const eof rune = -1
+
+// peekRune returns the next rune without advancing the lexer. eof is returned
+// at the end of the input.
+func (l *Lexer) peekRune() (rune, error) {
+	rns, err := l.Peek(1)
+	if len(rns) > 0 {
+		return rns[0], nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return eof, nil
+	}
+	return 0, err
+}
+
+// accept advances the lexer past s and returns true if the input at the
+// current position starts with s.
+func (l *Lexer) accept(s string) (bool, error) {
+	n := utf8.RuneCountInString(s)
+	rns, err := l.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("peeking input: %w", err)
+	}
+	if string(rns) != s {
+		return false, nil
+	}
+	if _, err := l.Advance(n); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 // Advance attempts to advance the underlying reader n runes and returns the
@@ -221,9 +477,8 @@ func (l *Lexer) Peek(n int) ([]rune, error) {
 // current lexeme position.
 func (l *Lexer) Advance(n int) (int, error) {
 	l.s.Lock()
-	a, err := l.advance(n, false)
-	l.s.Unlock()
-	return a, err
+	defer l.s.Unlock()
+	return l.advance(n, false)
 }
 
 func (l *Lexer) advance(n int, discard bool) (int, error) {
@@ -263,20 +518,30 @@ func (l *Lexer) advance(n int, discard bool) (int, error) {
 		//       of runes peeked.
 		for i := 0; i < d; i++ {
 			if rn[i] == '\n' {
-				l.s.line++
-				l.s.column = 0
+				l.newline(l.s.pos - d + i + 1)
 			} else {
 				l.s.column++
 			}
+			if l.s.slice == nil {
+				l.s.offset += runeLen(rn[i])
+			}
 		}
 
-		if !discard {
-			l.s.b.WriteString(string(rn))
+		// NOTE: Discarded runes are written to the lexeme value when
+		//       preserving trivia so that they are moved to the trivia in
+		//       order with any pending value by ignore.
+		if l.s.slice == nil && (!discard || l.s.keepTrivia) {
+			for _, r := range rn[:d] {
+				l.s.b = utf8.AppendRune(l.s.b, r)
+			}
 		}
 
 		if dErr != nil {
 			return advanced, fmt.Errorf("discarding input: %w", err)
 		}
+		if lErr := l.checkLimits(!discard); lErr != nil {
+			return advanced, lErr
+		}
 		if err != nil {
 			// EOF from Peek
 			//nolint:wrapcheck // Error doesn't need to be wrapped.
@@ -295,9 +560,8 @@ func (l *Lexer) advance(n int, discard bool) (int, error) {
 // position.
 func (l *Lexer) Discard(n int) (int, error) {
 	l.s.Lock()
-	d, err := l.advance(n, true)
-	l.s.Unlock()
-	return d, err
+	defer l.s.Unlock()
+	return l.advance(n, true)
 }
 
 // Find searches the input for one of the given tokens, advancing the reader,
@@ -308,9 +572,7 @@ func (l *Lexer) Find(tokens []string) (string, error) {
 
 	var maxLen int
 	for i := range tokens {
-		if len(tokens[i]) > maxLen {
-			maxLen = len(tokens[i])
-		}
+		maxLen = maxRuneLen(maxLen, tokens[i])
 	}
 
 	for {
@@ -339,9 +601,7 @@ func (l *Lexer) SkipTo(tokens []string) (string, error) {
 
 	var maxLen int
 	for i := range tokens {
-		if len(tokens[i]) > maxLen {
-			maxLen = len(tokens[i])
-		}
+		maxLen = maxRuneLen(maxLen, tokens[i])
 	}
 
 	for {
@@ -355,9 +615,16 @@ func (l *Lexer) SkipTo(tokens []string) (string, error) {
 			return "", fmt.Errorf("peeking input: %w", err)
 		}
 
-		for i := 0; i < len(rns)-maxLen+1; i++ {
+		// NOTE: At the end of the input, tokens shorter than the longest
+		//       token may still match in the last maxLen-1 runes.
+		limit := len(rns) - maxLen + 1
+		if errors.Is(err, io.EOF) {
+			limit = len(rns)
+		}
+		for i := 0; i < limit; i++ {
+			end := min(i+maxLen, len(rns))
 			for j := range tokens {
-				if strings.HasPrefix(string(rns[i:i+maxLen]), tokens[j]) {
+				if strings.HasPrefix(string(rns[i:end]), tokens[j]) {
 					// We have found a match. Discard prior runes and return.
 					if _, advErr := l.advance(i, true); advErr != nil {
 						return "", advErr
@@ -380,42 +647,165 @@ func (l *Lexer) SkipTo(tokens []string) (string, error) {
 	}
 }
 
+// newline updates the line and column for a newline. offset is the position
+// of the start of the next line. The lock must be held.
+func (l *Lexer) newline(offset int) {
+	l.s.line++
+	l.s.column = 0
+	if l.s.source != nil {
+		l.s.source.AddLine(offset)
+	}
+}
+
+// pending returns the length in bytes of the current lexeme value.
+func (l *Lexer) pending() int {
+	l.s.Lock()
+	defer l.s.Unlock()
+	if l.s.slice != nil {
+		return l.s.slice.Offset() - l.s.startOffset
+	}
+	return len(l.s.b)
+}
+
+// valueFrom returns the current lexeme value starting at the byte offset
+// returned by an earlier call to pending. Unlike current, the value is not
+// interned so that states can use it for scratch reads.
+func (l *Lexer) valueFrom(offset int) string {
+	l.s.Lock()
+	defer l.s.Unlock()
+	if l.s.slice != nil {
+		return l.s.slice.Slice(l.s.startOffset+offset, l.s.slice.Offset())
+	}
+	return string(l.s.b[offset:])
+}
+
+// current returns the current lexeme value, interning it if an Interner was
+// set and the value isn't sliced from the input. The lock must be held.
+func (l *Lexer) current() string {
+	switch {
+	case l.s.slice != nil:
+		return l.s.slice.Slice(l.s.startOffset, l.s.slice.Offset())
+	case l.s.interner != nil:
+		return l.s.interner.Intern(l.s.b)
+	default:
+		return string(l.s.b)
+	}
+}
+
 // Ignore ignores the previous input and resets the lexeme start position to
 // the current reader position.
 func (l *Lexer) Ignore() {
 	l.s.Lock()
+	defer l.s.Unlock()
 	l.ignore()
-	l.s.Unlock()
 }
 
 func (l *Lexer) ignore() {
+	if l.s.keepTrivia {
+		if l.s.slice != nil {
+			l.s.trivia.WriteString(l.current())
+		} else {
+			l.s.trivia.Write(l.s.b)
+		}
+	}
+	l.reset()
+}
+
+// reset resets the lexeme start position and value without preserving the
+// value as trivia.
+func (l *Lexer) reset() {
 	l.s.startPos = l.s.pos
 	l.s.startLine = l.s.line
 	l.s.startColumn = l.s.column
-	l.s.b = strings.Builder{}
+	l.s.b = l.s.b[:0]
+	l.s.startOffset = l.s.offset
+	if l.s.slice != nil {
+		l.s.startOffset = l.s.slice.Offset()
+	}
+}
+
+// runeLen returns the length of rn as written by utf8.AppendRune.
+func runeLen(rn rune) int {
+	if n := utf8.RuneLen(rn); n > 0 {
+		return n
+	}
+	return utf8.RuneLen(utf8.RuneError)
 }
 
+// DefaultBatchSize is the number of lexemes sent in each batch by a Lexer
+// started with LexBatches if no batch size was set with WithBatchSize.
+const DefaultBatchSize = 64
+
 // Lex starts a new goroutine to parse the content. Run is called on each state
 // starting with the initial state. Each state then returns the subsequent state
 // which is run until a state returns nil indicating that lexing has finished.
 //
-// The caller can request that the lexer stop by cancelling ctx. The
-// returned channel is closed when the Lexer is finished running.
+// The caller can request that the lexer stop by cancelling ctx or calling
+// Close. The returned channel is closed when the Lexer is finished running.
+// Calling Lex again returns the same channel. Calling Lex after Close or
+// LexBatches returns a channel that is closed without receiving any lexemes.
 func (l *Lexer) Lex(ctx context.Context) <-chan *Lexeme {
-	// This first goroutine ensures that the stop channel is closed when the
-	// given context is done. This requests that the other goroutine stop.
+	l.start(ctx, false)
+	return l.lexemes
+}
+
+// LexBatches is like Lex but emits the lexemes in batches to reduce the
+// overhead of channel communication on large inputs. Each batch holds up to
+// the batch size set by WithBatchSize lexemes and only the last batch may be
+// smaller. A lexeme is not delivered until its batch is full or the lexer
+// finishes running. Calling LexBatches after Close or Lex returns a channel
+// that is closed without receiving any batches.
+func (l *Lexer) LexBatches(ctx context.Context) <-chan []*Lexeme {
+	l.start(ctx, true)
+	return l.batches
+}
+
+// start starts the lexer goroutines if they haven't already been started.
+func (l *Lexer) start(ctx context.Context, batched bool) {
+	l.s.Lock()
+	if l.s.started {
+		l.s.Unlock()
+		return
+	}
+	l.s.started = true
+	timeout := l.s.limits.Timeout
+	l.s.Unlock()
+
+	l.batched = batched
+
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, &LimitError{
+			Limit: LimitTimeout,
+			Max:   int64(timeout),
+		})
+	}
+
+	l.wg.Add(2)
+
+	// This first goroutine requests that the other goroutine stop when the
+	// given context is done. It returns when the lexer is finished running
+	// so that it doesn't outlive the lexer if ctx is never cancelled.
 	go func() {
-		<-ctx.Done()
-		l.setErr(ctx.Err())
-		close(l.stop)
+		defer l.wg.Done()
+		defer cancel()
+		select {
+		case <-ctx.Done():
+			l.setErr(l.ctxErr(ctx))
+			l.requestStop()
+		case <-l.stop:
+		case <-l.done:
+		}
 	}()
 
 	// This goroutine runs the lexer. It will return and close the done and
 	// lexemes channels if stop is requested via the stop channel.
 	go func() {
 		var err error
+		defer l.wg.Done()
 		defer close(l.done)
 		defer close(l.lexemes)
+		defer close(l.batches)
 		for l.state != nil {
 			select {
 			case <-l.stop:
@@ -423,23 +813,135 @@ func (l *Lexer) Lex(ctx context.Context) <-chan *Lexeme {
 			default:
 			}
 
-			l.state, err = l.state.Run(ctx, l)
+			l.state, err = l.run(ctx)
 			if err != nil {
 				if !errors.Is(err, io.EOF) {
 					l.setErr(err)
 				}
-				return
+				break
 			}
 		}
+		l.flush()
+		_ = l.sendBatch()
 	}()
+}
 
-	return l.lexemes
+// run runs the current state and returns the next state. If panic recovery is
+// enabled, a panic is returned as a *PanicError.
+func (l *Lexer) run(ctx context.Context) (next State, err error) {
+	l.s.Lock()
+	recoverPanics := l.s.recoverPanics
+	l.s.Unlock()
+
+	if recoverPanics {
+		state := l.state
+		defer func() {
+			if v := recover(); v != nil {
+				pErr := newPanicError(v, stateName(state))
+				// NOTE: The lock is released by deferred calls as the
+				//       panic unwinds but may be briefly held by another
+				//       goroutine calling one of the Lexer's methods.
+				if l.s.TryLock() {
+					pErr.Pos, pErr.Line, pErr.Column = l.s.pos, l.s.line, l.s.column
+					l.s.Unlock()
+				}
+				next, err = nil, pErr
+			}
+		}()
+	}
+
+	return l.state.Run(ctx, l)
+}
+
+// ctxErr returns the error for the done context ctx. If the timeout limit
+// was exceeded a *LimitError at the current position is returned.
+func (l *Lexer) ctxErr(ctx context.Context) error {
+	var limitErr *LimitError
+	if !errors.As(context.Cause(ctx), &limitErr) {
+		return ctx.Err()
+	}
+	// NOTE: The lock may be held for a long time by a state in a call to
+	//       Find so the position is only recorded if it is available.
+	if l.s.TryLock() {
+		limitErr = l.limitError(limitErr.Limit, limitErr.Max)
+		l.s.Unlock()
+	}
+	return limitErr
+}
+
+// requestStop closes the stop channel if it isn't already closed.
+func (l *Lexer) requestStop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+// Close stops the lexer if it is running and waits for the goroutines started
+// by Lex to exit, which happens once the current state's Run returns. Lexemes
+// not yet received from the channel returned by Lex are discarded. Close is
+// safe to call more than once and may be called before Lex, in which case the
+// lexer will not run. Close always returns nil.
+func (l *Lexer) Close() error {
+	l.s.Lock()
+	started := l.s.started
+	l.s.started = true
+	l.s.Unlock()
+
+	l.requestStop()
+	if !started {
+		close(l.lexemes)
+		close(l.batches)
+		close(l.done)
+	}
+	l.wg.Wait()
+	return nil
+}
+
+// Reset closes the lexer and prepares it to lex the input from r starting at
+// state. Whether trivia is preserved, panic recovery and limits are retained.
+// The Source is cleared since r is a new input. Reset must not be called
+// concurrently with other methods of the Lexer.
+func (l *Lexer) Reset(r BufferedRuneReader, state State) {
+	_ = l.Close()
+
+	l.state = state
+	l.held = nil
+	l.lexemes = make(chan *Lexeme, l.bufferSize)
+	l.batches = make(chan []*Lexeme, l.bufferSize)
+	l.batched = false
+	l.batch = nil
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	l.stopOnce = sync.Once{}
+
+	l.s.Lock()
+	l.s.r = r
+	l.s.slice, _ = r.(SliceSource)
+	l.s.b = l.s.b[:0]
+	l.s.offset = 0
+	l.s.startOffset = 0
+	if l.s.slice != nil {
+		l.s.startOffset = l.s.slice.Offset()
+	}
+	l.s.pos = 0
+	l.s.line = 0
+	l.s.column = 0
+	l.s.startPos = 0
+	l.s.startLine = 0
+	l.s.startColumn = 0
+	l.s.err = nil
+	l.s.trivia.Reset()
+	l.s.started = false
+	l.s.emitted = 0
+	l.s.source = nil
+	l.s.Unlock()
 }
 
 // setErr sets the lexer's error value.
 func (l *Lexer) setErr(err error) {
 	l.s.Lock()
 	if l.s.err == nil {
+		setFilename(err, l.s.source.Name())
 		l.s.err = err
 	}
 	l.s.Unlock()
@@ -458,20 +960,33 @@ func (l *Lexer) Done() <-chan struct{} {
 	return l.done
 }
 
-// Lexeme returns a new Lexeme at the current position.
+// Lexeme returns a new Lexeme at the current position. The Lexeme is taken
+// from the pool used by NewLexeme. If the Lexer is reading from a SliceSource
+// the value is a slice of the input. Otherwise, it is interned if an Interner
+// was set.
 func (l *Lexer) Lexeme(typ LexemeType) *Lexeme {
 	l.s.Lock()
-	lexeme := &Lexeme{
-		Type:   typ,
-		Value:  l.s.b.String(),
-		Pos:    l.s.startPos,
-		Line:   l.s.startLine,
-		Column: l.s.startColumn,
-	}
-	l.s.Unlock()
+	defer l.s.Unlock()
+	lexeme := NewLexeme()
+	lexeme.Type = typ
+	lexeme.Value = l.current()
+	lexeme.Pos = l.s.startPos
+	lexeme.Offset = l.s.startOffset
+	lexeme.Line = l.s.startLine
+	lexeme.Column = l.s.startColumn
+	lexeme.Source = l.s.source
 	return lexeme
 }
 
+// EmitValue is like Emit but takes the lexeme by value. The lexeme is copied
+// to a Lexeme from the pool used by NewLexeme so that states can build
+// lexemes without allocating.
+func (l *Lexer) EmitValue(lexeme Lexeme) {
+	lx := NewLexeme()
+	*lx = lexeme
+	l.Emit(lx)
+}
+
 // Emit is used by State implementations to emit a lexeme which will be passed
 // on to the parser. If the lexer is not currently active, this is a no-op.
 func (l *Lexer) Emit(lexeme *Lexeme) {
@@ -481,10 +996,140 @@ func (l *Lexer) Emit(lexeme *Lexeme) {
 	if lexeme == nil {
 		return
 	}
+
+	l.s.Lock()
+	keep := l.s.keepTrivia
+	l.s.Unlock()
+
+	if !keep {
+		if l.send(lexeme) {
+			l.Ignore()
+		}
+		return
+	}
+
+	trivia := l.takeTrivia()
+	if l.held != nil {
+		l.held.Trailing, lexeme.Leading = splitTrivia(trivia)
+		if !l.send(l.held) {
+			return
+		}
+	} else {
+		lexeme.Leading = trivia
+	}
+	l.held = lexeme
+}
+
+// takeTrivia returns and clears the pending trivia and resets the lexeme
+// start position.
+func (l *Lexer) takeTrivia() string {
+	l.s.Lock()
+	defer l.s.Unlock()
+	trivia := l.s.trivia.String()
+	l.s.trivia.Reset()
+	l.reset()
+	return trivia
+}
+
+// flushTrivia moves any remaining input to the trivia and returns it. It
+// returns false if trivia is not preserved.
+func (l *Lexer) flushTrivia() (string, bool) {
+	l.s.Lock()
+	defer l.s.Unlock()
+	if !l.s.keepTrivia {
+		return "", false
+	}
+	l.ignore()
+	trivia := l.s.trivia.String()
+	l.s.trivia.Reset()
+	return trivia, true
+}
+
+// flush emits the held lexeme along with any remaining input as trivia. It is
+// a no-op if trivia is not preserved.
+func (l *Lexer) flush() {
+	trivia, ok := l.flushTrivia()
+	if !ok {
+		return
+	}
+
+	if l.held == nil {
+		if trivia == "" {
+			return
+		}
+		l.held = NewLexeme()
+		l.held.Type = TriviaType
+		l.held.Leading = trivia
+		l.held.Source = l.s.source
+	} else {
+		l.held.Trailing += trivia
+	}
+	_ = l.send(l.held)
+	l.held = nil
+}
+
+// send sends the lexeme to the lexemes channel. It returns false if the lexer
+// was stopped before the lexeme could be sent or the lexeme limit was
+// exceeded, in which case the lexer is stopped.
+func (l *Lexer) send(lexeme *Lexeme) bool {
+	l.s.Lock()
+	l.s.emitted++
+	var err error
+	if m := l.s.limits.MaxLexemes; m > 0 && l.s.emitted > m {
+		err = &LimitError{
+			Limit:  LimitLexemes,
+			Max:    int64(m),
+			Pos:    lexeme.Pos,
+			Line:   lexeme.Line,
+			Column: lexeme.Column,
+		}
+	}
+	l.s.Unlock()
+	if err != nil {
+		l.setErr(err)
+		l.requestStop()
+		return false
+	}
+
+	if l.batched {
+		l.batch = append(l.batch, lexeme)
+		if len(l.batch) < l.batchSize {
+			return true
+		}
+		return l.sendBatch()
+	}
+
 	select {
 	case l.lexemes <- lexeme:
-		l.Ignore()
+		return true
 	case <-l.stop:
-		return
+		return false
+	}
+}
+
+// sendBatch sends the pending batch of lexemes to the batches channel if it
+// isn't empty. It returns false if the lexer was stopped before the batch
+// could be sent.
+func (l *Lexer) sendBatch() bool {
+	if len(l.batch) == 0 {
+		return true
+	}
+	select {
+	case l.batches <- l.batch:
+		l.batch = make([]*Lexeme, 0, l.batchSize)
+		return true
+	case <-l.stop:
+		return false
+	}
+}
+
+// splitTrivia splits trivia into the trailing trivia of the previous lexeme,
+// up to and including the first newline, and the leading trivia of the next
+// lexeme.
+func splitTrivia(trivia string) (string, string) {
+	i := strings.IndexByte(trivia, '\n')
+	if i < 0 {
+		return trivia, ""
 	}
+	return trivia[:i+1], trivia[i+1:]
 }
@@ -20,6 +20,7 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/google/go-cmp/cmp"
@@ -525,6 +526,27 @@ func TestLexer_SkipTo(t *testing.T) {
 		}
 	})
 
+	t.Run("match at end", func(t *testing.T) {
+		t.Parallel()
+
+		// The multi-byte token must be matched even though fewer runes
+		// than its length in bytes remain, as must the shorter token.
+		for input, want := range map[string]string{"Helloé": "é", "Hello!": "!"} {
+			l := NewLexer(runeio.NewReader(strings.NewReader(input)), &wordState{})
+
+			token, err := l.SkipTo([]string{"é", "!", "long"})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if got := token; got != want {
+				t.Errorf("unexpected token: want: %q, got: %q", want, got)
+			}
+			if got, want := l.Pos(), 5; got != want {
+				t.Errorf("Pos: want: %v, got: %v", want, got)
+			}
+		}
+	})
+
 	t.Run("no match", func(t *testing.T) {
 		t.Parallel()
 
@@ -591,6 +613,7 @@ func TestLexer_lexemes(t *testing.T) {
 			Type:   wordType,
 			Value:  "Lexemes!",
 			Pos:    6,
+			Offset: 6,
 			Line:   0,
 			Column: 6,
 		},
@@ -604,3 +627,142 @@ func TestLexer_lexemes(t *testing.T) {
 		t.Errorf("unexpected output (-want +got):\n%s", diff)
 	}
 }
+
+// closeWithin calls l.Close and fails the test if it doesn't return promptly.
+func closeWithin(t *testing.T, l *Lexer) {
+	t.Helper()
+
+	closed := make(chan struct{})
+	go func() {
+		_ = l.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not return")
+	}
+}
+
+func TestLexer_Close(t *testing.T) {
+	t.Parallel()
+
+	t.Run("after lexing", func(t *testing.T) {
+		t.Parallel()
+
+		// The context is never cancelled but Close must still return
+		// once lexing has finished.
+		l := NewLexer(runeio.NewReader(strings.NewReader("Hello Lexemes!")), &wordState{})
+		lexemes := l.Lex(context.Background())
+		for range lexemes {
+			// Drain the remaining lexemes.
+		}
+		closeWithin(t, l)
+		closeWithin(t, l)
+
+		if err := l.Err(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("while lexing", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewLexer(runeio.NewReader(strings.NewReader("Hello Lexemes!")), &wordState{})
+		lexemes := l.Lex(context.Background())
+		<-lexemes
+		closeWithin(t, l)
+
+		for range lexemes {
+			// Drain the remaining lexemes.
+		}
+		// Calling Lex again doesn't restart the lexer.
+		if _, ok := <-l.Lex(context.Background()); ok {
+			t.Errorf("Lex after Close: channel not closed")
+		}
+	})
+
+	t.Run("before lex", func(t *testing.T) {
+		t.Parallel()
+
+		l := NewLexer(runeio.NewReader(strings.NewReader("Hello Lexemes!")), &wordState{})
+		closeWithin(t, l)
+
+		if _, ok := <-l.Lex(context.Background()); ok {
+			t.Errorf("Lex after Close: channel not closed")
+		}
+		<-l.Done()
+	})
+}
+
+func TestLexer_Reset(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("Hello Lexemes!")), &wordState{})
+	lexemes := l.Lex(context.Background())
+	<-lexemes
+
+	l.Reset(runeio.NewReader(strings.NewReader("Second\ninput")), &wordState{})
+
+	var got []*Lexeme
+	for lexeme := range l.Lex(context.Background()) {
+		got = append(got, lexeme)
+	}
+	want := []*Lexeme{
+		{Type: wordType, Value: "Second"},
+		{Type: wordType, Value: "input", Pos: 7, Offset: 7, Line: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected output (-want +got):\n%s", diff)
+	}
+	if err := l.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	closeWithin(t, l)
+}
+
+func TestLexer_negativeBufferSize(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("A B")), &wordState{}, WithBufferSize(-1))
+
+	var got []string
+	for lexeme := range l.Lex(context.Background()) {
+		got = append(got, lexeme.Value)
+	}
+	if diff := cmp.Diff([]string{"A", "B"}, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+	closeWithin(t, l)
+}
+
+func TestLexer_LexBatches(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("A B C D E")), &wordState{},
+		WithBatchSize(2),
+		WithBufferSize(1),
+	)
+
+	var got [][]string
+	for batch := range l.LexBatches(context.Background()) {
+		var values []string
+		for _, lexeme := range batch {
+			values = append(values, lexeme.Value)
+		}
+		got = append(got, values)
+	}
+	want := [][]string{{"A", "B"}, {"C", "D"}, {"E"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected batches (-want +got):\n%s", diff)
+	}
+	if err := l.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Lex doesn't restart the lexer.
+	if _, ok := <-l.Lex(context.Background()); ok {
+		t.Errorf("Lex after LexBatches: channel not closed")
+	}
+	closeWithin(t, l)
+}
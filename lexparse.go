@@ -19,37 +19,76 @@ package lexparse
 import (
 	"context"
 	"errors"
+	"io"
 )
 
 // LexParse lexes the content starting at initState and passes the results to a
-// parser starting at initFn. The resulting root node of the parse tree is returned.
+// parser starting at initFn. The resulting root node of the parse tree is
+// returned. Any lexing and parsing errors are returned joined together.
 func LexParse[V comparable](
 	ctx context.Context,
 	r BufferedRuneReader,
 	initState State,
 	initFn ParseFn[V],
+	opts ...Option,
 ) (*Node[V], error) {
-	l := NewLexer(r, initState)
+	o := newOptions(opts)
+	l := NewLexer(r, initState, opts...)
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	p := NewParser[V](l.Lex(ctx))
+	var src LexemeSource
+	if o.batchSize > 0 {
+		src = BatchSource(l.LexBatches(ctx))
+	} else {
+		src = ChannelSource(l.Lex(ctx))
+	}
+	src = Pipeline(src, o.transforms...)
+	p := NewSourceParser[V](src, opts...)
 	n, pErr := p.Parse(ctx, initFn)
 	cancel()
 
-	<-l.Done()
+	_ = l.Close()
 
-	// Check for lexing error.
-	var err error
+	// The lexer is cancelled once parsing is done so cancellation isn't an
+	// error.
 	lErr := l.Err()
-	if lErr != nil && !errors.Is(lErr, context.Canceled) {
-		err = lErr
+	if errors.Is(lErr, context.Canceled) {
+		lErr = nil
 	}
 
-	// If no lexing error return parsing error.
-	if err == nil {
-		err = pErr
+	switch {
+	case lErr == nil:
+		return n, pErr
+	case pErr == nil:
+		return n, lErr
+	default:
+		return n, errors.Join(lErr, pErr)
 	}
+}
+
+// LexParseString is like LexParse but lexes the string s. Lexeme values are
+// sliced from s rather than copied. See NewStringReader.
+func LexParseString[V comparable](
+	ctx context.Context,
+	s string,
+	initState State,
+	initFn ParseFn[V],
+	opts ...Option,
+) (*Node[V], error) {
+	return LexParse(ctx, NewStringReader(s), initState, initFn, opts...)
+}
 
-	return n, err
+// LexParseReader is like LexParse but lexes text read from r. The encoding is
+// detected from the byte order mark, defaulting to UTF-8, and invalid input is
+// replaced with U+FFFD. Use NewReader and LexParse to configure the buffer
+// size, the encoding or how invalid input is handled.
+func LexParseReader[V comparable](
+	ctx context.Context,
+	r io.Reader,
+	initState State,
+	initFn ParseFn[V],
+	opts ...Option,
+) (*Node[V], error) {
+	return LexParse(ctx, NewReader(r, ReaderOptions{}), initState, initFn, opts...)
 }
@@ -106,4 +106,97 @@ func TestLexParse(t *testing.T) {
 			t.Errorf("unexpected error (-want +got):\n%s", diff)
 		}
 	})
+	// Test when both the lexer and parser encounter errors.
+	t.Run("joined errors", func(t *testing.T) {
+		t.Parallel()
+
+		r := runeio.NewReader(strings.NewReader("Hello\nWorld!"))
+
+		_, err := LexParse(context.Background(), r, StateFn(errStateFn), errParseFn)
+		if !errors.Is(err, errState) {
+			t.Errorf("lexer error not returned: %v", err)
+		}
+		if !errors.Is(err, errParse) {
+			t.Errorf("parser error not returned: %v", err)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+
+		r := runeio.NewReader(strings.NewReader("Hello World!\n"))
+
+		upper := func(src LexemeSource) LexemeSource {
+			return LexemeSourceFunc(func() *Lexeme {
+				l := src.Next()
+				if l != nil {
+					l.Value = strings.ToUpper(l.Value)
+				}
+				return l
+			})
+		}
+		got, err := LexParse(context.Background(), r, &wordState{}, parseWord,
+			WithTrivia(true),
+			WithTransforms(DropTypes(TriviaType), upper),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := []string{"HELLO", "WORLD!"}
+		var values []string
+		for _, c := range got.Children {
+			values = append(values, c.Value)
+		}
+		if got, want := got.Children[1].Trailing, "\n"; got != want {
+			t.Errorf("Trailing: want: %q, got: %q", want, got)
+		}
+		if diff := cmp.Diff(want, values); diff != "" {
+			t.Errorf("unexpected output (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		t.Parallel()
+
+		r := runeio.NewReader(strings.NewReader("A B C"))
+
+		_, err := LexParse(context.Background(), r, &wordState{}, parseWord,
+			WithLimits(Limits{MaxNodes: 1}),
+		)
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != LimitNodes {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("panic recovery", func(t *testing.T) {
+		t.Parallel()
+
+		r := runeio.NewReader(strings.NewReader("A B C"))
+
+		_, err := LexParse(context.Background(), r, panicAfter(1), parseWord,
+			WithPanicRecovery(true),
+		)
+		var pErr *PanicError
+		if !errors.As(err, &pErr) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	t.Run("batched", func(t *testing.T) {
+		t.Parallel()
+
+		r := runeio.NewReader(strings.NewReader("A B C D E"))
+
+		got, err := LexParse(context.Background(), r, &wordState{}, parseWord,
+			WithBufferSize(2),
+			WithBatchSize(2),
+		)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := len(got.Children), 5; got != want {
+			t.Errorf("Children: want: %d, got: %d", want, got)
+		}
+	})
 }
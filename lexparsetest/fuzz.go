@@ -0,0 +1,264 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparsetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ianlewis/lexparse"
+)
+
+// ErrInvariant indicates that a library invariant does not hold.
+var ErrInvariant = errors.New("invariant violated")
+
+// DefaultLeakTimeout is the time to wait for LexParse to return if
+// FuzzOptions.LeakTimeout is not set.
+const DefaultLeakTimeout = time.Second
+
+// FuzzOptions configures Fuzz and Check.
+type FuzzOptions struct {
+	// IgnoreValues disables the check that each lexeme's value is found in
+	// the input at the lexeme's position. It should be set for lexers whose
+	// lexeme values differ from the input, e.g. by unescaping strings.
+	IgnoreValues bool
+
+	// LeakTimeout is the time to wait for LexParse to return. LexParse
+	// doesn't return until the lexer's goroutines have exited. If zero,
+	// DefaultLeakTimeout is used.
+	LeakTimeout time.Duration
+}
+
+// Fuzz runs a fuzz target that lexes and parses each input starting at the
+// state returned by newState and the parse function fn and checks the
+// library invariants using Check. Seed inputs should be added using f.Add
+// before calling Fuzz. Panics in the lexer or parser fail the target.
+func Fuzz[V comparable](
+	f *testing.F,
+	newState func() lexparse.State,
+	fn lexparse.ParseFn[V],
+	opts FuzzOptions,
+) {
+	f.Helper()
+
+	f.Fuzz(func(t *testing.T, input string) {
+		if err := Check(input, newState, fn, opts); err != nil {
+			t.Fatalf("input %q: %v", input, err)
+		}
+	})
+}
+
+// Check lexes and parses input with LexParse and checks the library
+// invariants:
+//
+//   - LexParse returns within opts.LeakTimeout and the lexer's goroutines
+//     have finished when it returns.
+//   - Lexeme positions are monotonic, within the input and consistent with
+//     their line and column (see CheckLexemes).
+//   - Lexeme values are found in the input at their position unless
+//     opts.IgnoreValues is set.
+//   - The tree's Parent links are consistent with its Children and node
+//     positions are within the input (see CheckTree).
+//
+// Lexing and parsing errors are not invariant violations and are ignored.
+// All violations found are returned joined together.
+func Check[V comparable](
+	input string,
+	newState func() lexparse.State,
+	fn lexparse.ParseFn[V],
+	opts FuzzOptions,
+) error {
+	state := &lexerState{State: newState()}
+	var lexemes []*lexparse.Lexeme
+	record := func(src lexparse.LexemeSource) lexparse.LexemeSource {
+		return lexparse.LexemeSourceFunc(func() *lexparse.Lexeme {
+			lexeme := src.Next()
+			if lexeme != nil {
+				lexemes = append(lexemes, lexeme)
+			}
+			return lexeme
+		})
+	}
+
+	done := make(chan *lexparse.Node[V], 1)
+	go func() {
+		root, _ := lexparse.LexParseString(context.Background(), input, state, fn, lexparse.WithTransforms(record))
+		done <- root
+	}()
+
+	timeout := opts.LeakTimeout
+	if timeout == 0 {
+		timeout = DefaultLeakTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var root *lexparse.Node[V]
+	select {
+	case root = <-done:
+	case <-timer.C:
+		return fmt.Errorf("%w: LexParse did not return within %v", ErrInvariant, timeout)
+	}
+
+	return errors.Join(
+		checkDone(state.l),
+		CheckLexemes(input, lexemes, opts.IgnoreValues),
+		CheckTree(input, root),
+	)
+}
+
+// lexerState is a State that records the Lexer that runs it and then runs
+// the wrapped State.
+type lexerState struct {
+	lexparse.State
+
+	// l is the Lexer that ran the state, if it was run.
+	l *lexparse.Lexer
+}
+
+// Run implements lexparse.State.
+func (s *lexerState) Run(ctx context.Context, l *lexparse.Lexer) (lexparse.State, error) {
+	s.l = l
+	//nolint:wrapcheck // The wrapped state's errors are returned as is.
+	return s.State.Run(ctx, l)
+}
+
+// checkDone checks that the lexer l has finished running. Only l's own
+// goroutines are checked so that the result is not affected by other tests
+// running in parallel. l is nil if the lexer never ran a state.
+func checkDone(l *lexparse.Lexer) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case <-l.Done():
+		return nil
+	default:
+		return fmt.Errorf("%w: lexer still running after LexParse returned", ErrInvariant)
+	}
+}
+
+// position is a position in the input.
+type position struct {
+	line, column int
+}
+
+// positions returns the line and column of each rune offset in input,
+// including the offset at the end of the input.
+func positions(input []rune) []position {
+	pos := make([]position, 0, len(input)+1)
+	var cur position
+	for _, r := range input {
+		pos = append(pos, cur)
+		if r == '\n' {
+			cur.line++
+			cur.column = 0
+		} else {
+			cur.column++
+		}
+	}
+	return append(pos, cur)
+}
+
+// CheckLexemes checks that the lexemes' positions are monotonic, within the
+// input and consistent with their line and column. Unless ignoreValues is
+// set, it also checks that each lexeme's value is found in the input at its
+// position. Lexemes of type lexparse.TriviaType are not checked.
+func CheckLexemes(input string, lexemes []*lexparse.Lexeme, ignoreValues bool) error {
+	runes := []rune(input)
+	pos := positions(runes)
+
+	var errs []error
+	last := 0
+	for i, lexeme := range lexemes {
+		if lexeme.Type == lexparse.TriviaType {
+			continue
+		}
+		if lexeme.Pos < 0 || lexeme.Pos > len(runes) {
+			errs = append(errs, fmt.Errorf("%w: lexeme %d: position %d outside input of length %d",
+				ErrInvariant, i, lexeme.Pos, len(runes)))
+			continue
+		}
+		if lexeme.Pos < last {
+			errs = append(errs, fmt.Errorf("%w: lexeme %d: position %d before previous position %d",
+				ErrInvariant, i, lexeme.Pos, last))
+		}
+		last = lexeme.Pos
+
+		if want := pos[lexeme.Pos]; lexeme.Line != want.line || lexeme.Column != want.column {
+			errs = append(errs, fmt.Errorf("%w: lexeme %d: line:column %d:%d inconsistent with position %d (%d:%d)",
+				ErrInvariant, i, lexeme.Line, lexeme.Column, lexeme.Pos, want.line, want.column))
+		}
+
+		if ignoreValues {
+			continue
+		}
+		value := []rune(lexeme.Value)
+		end := lexeme.Pos + len(value)
+		if end > len(runes) || string(runes[lexeme.Pos:end]) != string(value) {
+			errs = append(errs, fmt.Errorf("%w: lexeme %d: value %q not found in input at position %d",
+				ErrInvariant, i, lexeme.Value, lexeme.Pos))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CheckTree checks that the Parent links of the tree rooted at root are
+// consistent with the Children, that the root has no parent, and that node
+// positions are within the input and consistent with their line and column.
+func CheckTree[V comparable](input string, root *lexparse.Node[V]) error {
+	if root == nil {
+		return nil
+	}
+	runes := []rune(input)
+	pos := positions(runes)
+
+	var errs []error
+	if root.Parent != nil {
+		errs = append(errs, fmt.Errorf("%w: root has a parent", ErrInvariant))
+	}
+	for n := range lexparse.PreOrder(root) {
+		path := nodePath(n, root)
+		if n.Pos < 0 || n.Pos > len(runes) {
+			errs = append(errs, fmt.Errorf("%w: node %s: position %d outside input of length %d",
+				ErrInvariant, path, n.Pos, len(runes)))
+		} else if want := pos[n.Pos]; n.Line != want.line || n.Column != want.column {
+			errs = append(errs, fmt.Errorf("%w: node %s: line:column %d:%d inconsistent with position %d (%d:%d)",
+				ErrInvariant, path, n.Line, n.Column, n.Pos, want.line, want.column))
+		}
+		for i, c := range n.Children {
+			if c == nil {
+				errs = append(errs, fmt.Errorf("%w: node %s: child %d is nil", ErrInvariant, path, i))
+				continue
+			}
+			if c.Parent != n {
+				errs = append(errs, fmt.Errorf("%w: node %s: child %d has inconsistent parent", ErrInvariant, path, i))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nodePath returns the path from root to n.
+func nodePath[V comparable](n, root *lexparse.Node[V]) lexparse.Path {
+	var path lexparse.Path
+	for ; n != root && n.Parent != nil; n = n.Parent {
+		path = append(lexparse.Path{n.Index()}, path...)
+	}
+	return path
+}
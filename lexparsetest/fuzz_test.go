@@ -0,0 +1,130 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparsetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ianlewis/lexparse"
+)
+
+func FuzzWords(f *testing.F) {
+	for _, seed := range []string{"", "hello world\n", "héllo\twörld", "\xff\xfe a"} {
+		f.Add(seed)
+	}
+	Fuzz(f, lexWords, parseWords, FuzzOptions{})
+}
+
+func TestCheckLexemes(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		lexemes []*lexparse.Lexeme
+		err     error
+	}{
+		"valid": {
+			lexemes: []*lexparse.Lexeme{
+				{Value: "hé", Pos: 0},
+				{Value: "b", Pos: 3, Line: 1, Column: 0},
+			},
+		},
+		"outside input": {
+			lexemes: []*lexparse.Lexeme{{Value: "", Pos: 10}},
+			err:     ErrInvariant,
+		},
+		"not monotonic": {
+			lexemes: []*lexparse.Lexeme{
+				{Value: "b", Pos: 3, Line: 1, Column: 0},
+				{Value: "hé", Pos: 0},
+			},
+			err: ErrInvariant,
+		},
+		"line column": {
+			lexemes: []*lexparse.Lexeme{{Value: "b", Pos: 3, Line: 0, Column: 3}},
+			err:     ErrInvariant,
+		},
+		"value": {
+			lexemes: []*lexparse.Lexeme{{Value: "he", Pos: 0}},
+			err:     ErrInvariant,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := CheckLexemes("hé\nb", tc.lexemes, false); !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestCheckTree(t *testing.T) {
+	t.Parallel()
+
+	child := &lexparse.Node[string]{Value: "b", Pos: 3, Line: 1}
+	root := &lexparse.Node[string]{Children: []*lexparse.Node[string]{child}}
+	child.Parent = root
+
+	if err := CheckTree("hé\nb", root); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	child.Parent = nil
+	if err := CheckTree("hé\nb", root); !errors.Is(err, ErrInvariant) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	child.Parent = root
+	child.Column = 1
+	if err := CheckTree("hé\nb", root); !errors.Is(err, ErrInvariant) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCheck_leak(t *testing.T) {
+	t.Parallel()
+
+	// The state ignores the context so the lexer can't be stopped until
+	// release is closed.
+	running := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	block := func() lexparse.State {
+		return lexparse.StateFn(func(context.Context, *lexparse.Lexer) (lexparse.State, error) {
+			close(running)
+			<-release
+			return nil, io.EOF
+		})
+	}
+
+	// The parser returns without reading any lexemes once the state is
+	// running.
+	parseNone := func(context.Context, *lexparse.Parser[string]) (lexparse.ParseFn[string], error) {
+		<-running
+		return nil, nil
+	}
+
+	err := Check("a", block, parseNone, FuzzOptions{LeakTimeout: 10 * time.Millisecond})
+	if !errors.Is(err, ErrInvariant) {
+		t.Errorf("unexpected error: want: %v, got: %v", ErrInvariant, err)
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lexparsetest provides golden file testing helpers for lexers and
+// parsers built with lexparse.
+//
+// Each helper runs over every input file in a directory and compares the
+// output to a golden file of the same name with an extension added. Run the
+// tests with the -update flag to write the golden files instead:
+//
+//	go test . -update
+//
+// The flag is only defined in test binaries that import this package, so
+// set the UPDATE_GOLDEN environment variable instead to update the golden
+// files of several packages at once:
+//
+//	UPDATE_GOLDEN=1 go test ./...
+package lexparsetest
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ianlewis/lexparse"
+)
+
+const (
+	// GoldenExt is the extension of golden files. Input files with this
+	// extension are ignored.
+	GoldenExt = ".golden"
+
+	// LexGoldenExt is the extension added to input file names to get the
+	// name of the golden file used by Lex.
+	LexGoldenExt = ".lex" + GoldenExt
+
+	// ParseGoldenExt is the extension added to input file names to get the
+	// name of the golden file used by Parse.
+	ParseGoldenExt = ".parse" + GoldenExt
+
+	// UpdateEnv is the environment variable that, when set to a non-empty
+	// value, makes the helpers write the golden files instead of comparing
+	// against them. It is an alternative to the -update flag.
+	UpdateEnv = "UPDATE_GOLDEN"
+
+	// maxDiffCells is the maximum size of the table used by lineDiff. Larger
+	// diffs only report the first differing line.
+	maxDiffCells = 1 << 20
+
+	// diffContext is the number of unchanged lines shown around changes.
+	diffContext = 3
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// LexOptions configures Lex.
+type LexOptions struct {
+	// Glob selects the input files in the directory. If empty, all files
+	// without the GoldenExt extension are used.
+	Glob string
+
+	// TypeName returns the name of a lexeme type. If nil, types are printed
+	// as numbers.
+	TypeName func(lexparse.LexemeType) string
+}
+
+// Lex runs the lexer starting at the state returned by newState over each
+// input file in dir and compares the lexemes to the golden files with the
+// LexGoldenExt extension. Each lexeme is printed on its own line along with
+// its one-indexed line and column. A lexing error is printed on the last line.
+func Lex(t *testing.T, dir string, newState func() lexparse.State, opts LexOptions) {
+	t.Helper()
+
+	typeName := opts.TypeName
+	if typeName == nil {
+		typeName = func(typ lexparse.LexemeType) string {
+			return fmt.Sprint(int(typ))
+		}
+	}
+
+	for _, path := range inputs(t, dir, opts.Glob) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Helper()
+
+			input := readFile(t, path)
+			l := lexparse.NewLexer(lexparse.NewStringReader(input), newState())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var b strings.Builder
+			for lexeme := range l.Lex(ctx) {
+				fmt.Fprintf(&b, "%d:%d %s %q\n", lexeme.Line+1, lexeme.Column+1, typeName(lexeme.Type), lexeme.Value)
+			}
+			<-l.Done()
+			if err := l.Err(); err != nil {
+				fmt.Fprintf(&b, "error: %v\n", err)
+			}
+
+			Compare(t, path+LexGoldenExt, b.String())
+		})
+	}
+}
+
+// ParseOptions configures Parse.
+type ParseOptions[V comparable] struct {
+	// Glob selects the input files in the directory. If empty, all files
+	// without the GoldenExt extension are used.
+	Glob string
+
+	// Print configures how the parse tree is printed.
+	Print lexparse.PrintOptions[V]
+}
+
+// Parse runs LexParse starting at the state returned by newState and the
+// parse function fn over each input file in dir and compares the printed
+// tree to the golden files with the ParseGoldenExt extension. A parsing error
+// is printed on the last line.
+func Parse[V comparable](
+	t *testing.T,
+	dir string,
+	newState func() lexparse.State,
+	fn lexparse.ParseFn[V],
+	opts ParseOptions[V],
+) {
+	t.Helper()
+
+	for _, path := range inputs(t, dir, opts.Glob) {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			t.Helper()
+
+			input := readFile(t, path)
+			root, err := lexparse.LexParseString(context.Background(), input, newState(), fn)
+
+			var b strings.Builder
+			if pErr := lexparse.Fprint(&b, root, opts.Print); pErr != nil {
+				t.Fatalf("printing tree: %v", pErr)
+			}
+			if err != nil {
+				fmt.Fprintf(&b, "error: %v\n", err)
+			}
+
+			Compare(t, path+ParseGoldenExt, b.String())
+		})
+	}
+}
+
+// Compare compares got to the contents of the golden file at path and
+// reports a line diff on mismatch. If the -update flag or the UpdateEnv
+// environment variable is set, the golden file is written instead.
+func Compare(t testing.TB, path, got string) {
+	t.Helper()
+
+	if *update || os.Getenv(UpdateEnv) != "" {
+		//nolint:gosec // Golden files are not sensitive.
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("updating golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing golden file %s: run with -update to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+	if diff := lineDiff(string(want), got); diff != "" {
+		t.Errorf("%s: output differs from golden file (-want +got):\n%s", path, diff)
+	}
+}
+
+// lineDiff returns a diff of the lines of want and got or an empty string if
+// they are equal. Removed lines are prefixed with "-", added lines with "+"
+// and unchanged lines with a space. Only diffContext unchanged lines are shown
+// before and after the changes. If the changed lines are too many to diff,
+// only the first differing line is reported.
+func lineDiff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// Trim the common prefix and suffix.
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var s strings.Builder
+	ctxStart := max(prefix-diffContext, 0)
+	if ctxStart > 0 {
+		fmt.Fprintf(&s, "@@ line %d @@\n", ctxStart+1)
+	}
+	for _, line := range a[ctxStart:prefix] {
+		fmt.Fprintf(&s, "  %s\n", line)
+	}
+
+	ca := a[prefix : len(a)-suffix]
+	cb := b[prefix : len(b)-suffix]
+	if (len(ca)+1)*(len(cb)+1) > maxDiffCells {
+		fmt.Fprintf(&s, "first difference at line %d (too many changes to diff)\n", prefix+1)
+		if len(ca) > 0 {
+			fmt.Fprintf(&s, "- %s\n", ca[0])
+		}
+		if len(cb) > 0 {
+			fmt.Fprintf(&s, "+ %s\n", cb[0])
+		}
+		return s.String()
+	}
+	diffLines(&s, ca, cb)
+
+	for _, line := range a[len(a)-suffix : len(a)-suffix+min(suffix, diffContext)] {
+		fmt.Fprintf(&s, "  %s\n", line)
+	}
+	return s.String()
+}
+
+// diffLines writes a diff of the lines a and b to s using the longest common
+// subsequence of the lines.
+func diffLines(s *strings.Builder, a, b []string) {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(s, "  %s\n", a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(s, "- %s\n", a[i])
+			i++
+		default:
+			fmt.Fprintf(s, "+ %s\n", b[j])
+			j++
+		}
+	}
+}
+
+// inputs returns the input files in dir matching glob.
+func inputs(t *testing.T, dir, glob string) []string {
+	t.Helper()
+
+	if glob == "" {
+		glob = "*"
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, glob))
+	if err != nil {
+		t.Fatalf("invalid glob %q: %v", glob, err)
+	}
+
+	var paths []string
+	for _, path := range matches {
+		if strings.HasSuffix(path, GoldenExt) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("reading input: %v", err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no input files in %s", dir)
+	}
+	return paths
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading input: %v", err)
+	}
+	return string(b)
+}
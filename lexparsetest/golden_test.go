@@ -0,0 +1,144 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparsetest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/ianlewis/lexparse"
+)
+
+const wordType lexparse.LexemeType = iota
+
+// lexWords emits each whitespace separated word.
+func lexWords() lexparse.State {
+	return lexparse.StateFn(func(_ context.Context, l *lexparse.Lexer) (lexparse.State, error) {
+		for {
+			rn, err := l.Peek(1)
+			if errors.Is(err, io.EOF) {
+				l.Emit(l.Lexeme(wordType))
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, err
+			}
+			if unicode.IsSpace(rn[0]) {
+				l.Emit(l.Lexeme(wordType))
+				if _, err := l.Discard(1); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, err := l.Advance(1); err != nil {
+				return nil, err
+			}
+		}
+	})
+}
+
+// parseWords adds each non-empty word as a child of the root.
+func parseWords(_ context.Context, p *lexparse.Parser[string]) (lexparse.ParseFn[string], error) {
+	for {
+		l := p.Peek()
+		if l == nil {
+			return nil, nil
+		}
+		if l.Value != "" {
+			p.Node(l.Value)
+		}
+		_ = p.Next()
+	}
+}
+
+func TestLex(t *testing.T) {
+	t.Parallel()
+
+	Lex(t, "testdata", lexWords, LexOptions{
+		Glob: "*.txt",
+		TypeName: func(lexparse.LexemeType) string {
+			return "word"
+		},
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	Parse(t, "testdata", lexWords, parseWords, ParseOptions[string]{
+		Glob: "*.txt",
+		Print: lexparse.PrintOptions[string]{
+			Value:     strconv.Quote,
+			Positions: true,
+		},
+	})
+}
+
+func TestLineDiff(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		want string
+		got  string
+		diff string
+	}{
+		"equal": {
+			want: "a\nb\n",
+			got:  "a\nb\n",
+			diff: "",
+		},
+		"changed": {
+			want: "a\nb\nc",
+			got:  "a\nx\nc",
+			diff: "  a\n- b\n+ x\n  c\n",
+		},
+		"added": {
+			want: "a",
+			got:  "a\nb",
+			diff: "  a\n+ b\n",
+		},
+		"removed": {
+			want: "a\nb",
+			got:  "b",
+			diff: "- a\n  b\n",
+		},
+		"context": {
+			want: "1\n2\n3\n4\n5\nb\n6\n7\n8\n9",
+			got:  "1\n2\n3\n4\n5\nx\n6\n7\n8\n9",
+			diff: "@@ line 3 @@\n  3\n  4\n  5\n- b\n+ x\n  6\n  7\n  8\n",
+		},
+		"too large": {
+			want: "a\n" + strings.Repeat("b\n", 2000) + "c",
+			got:  "a\n" + strings.Repeat("x\n", 2000) + "c",
+			diff: "  a\nfirst difference at line 2 (too many changes to diff)\n- b\n+ x\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := lineDiff(tc.want, tc.got), tc.diff; got != want {
+				t.Errorf("lineDiff: want: %q, got: %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLimitExceeded indicates that a resource limit was exceeded. Errors
+// returned for exceeded limits are of type *LimitError and wrap
+// ErrLimitExceeded.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits caps the resources used by a Lexer or Parser. Zero values mean no
+// limit.
+type Limits struct {
+	// MaxInputRunes is the maximum number of runes read from the input.
+	// It is enforced by the Lexer.
+	MaxInputRunes int
+
+	// MaxLexemes is the maximum number of lexemes emitted. It is enforced by
+	// the Lexer.
+	MaxLexemes int
+
+	// MaxLexemeLength is the maximum length in runes of a lexeme's value. It
+	// is enforced by the Lexer.
+	MaxLexemeLength int
+
+	// MaxDepth is the maximum depth of a node in the parse tree. The root is
+	// at depth zero. It is enforced by the Parser.
+	MaxDepth int
+
+	// MaxNodes is the maximum number of nodes created, not counting the
+	// root. It is enforced by the Parser.
+	MaxNodes int
+
+	// Timeout is the maximum wall-clock time that lexing or parsing may
+	// take. It is enforced by the Lexer and Parser separately.
+	Timeout time.Duration
+}
+
+// Limit identifies one of the Limits.
+type Limit int
+
+const (
+	// LimitInputRunes is Limits.MaxInputRunes.
+	LimitInputRunes Limit = iota + 1
+
+	// LimitLexemes is Limits.MaxLexemes.
+	LimitLexemes
+
+	// LimitLexemeLength is Limits.MaxLexemeLength.
+	LimitLexemeLength
+
+	// LimitDepth is Limits.MaxDepth.
+	LimitDepth
+
+	// LimitNodes is Limits.MaxNodes.
+	LimitNodes
+
+	// LimitTimeout is Limits.Timeout.
+	LimitTimeout
+)
+
+// String implements fmt.Stringer.
+func (l Limit) String() string {
+	switch l {
+	case LimitInputRunes:
+		return "input runes"
+	case LimitLexemes:
+		return "lexemes"
+	case LimitLexemeLength:
+		return "lexeme length"
+	case LimitDepth:
+		return "tree depth"
+	case LimitNodes:
+		return "nodes"
+	case LimitTimeout:
+		return "timeout"
+	default:
+		return fmt.Sprintf("Limit(%d)", int(l))
+	}
+}
+
+// LimitError is returned when a limit is exceeded. It records the limit and
+// the position in the input where it was exceeded.
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+
+	// Max is the value of the limit. For LimitTimeout it is a
+	// time.Duration.
+	Max int64
+
+	// Pos is the position in the input where the limit was exceeded.
+	Pos int
+
+	// Line is the line number in the input where the limit was exceeded.
+	Line int
+
+	// Column is the column in the line where the limit was exceeded.
+	Column int
+
+	// Filename is the name of the Source being processed, if any.
+	Filename string
+}
+
+// Error implements error.
+func (e *LimitError) Error() string {
+	maxValue := fmt.Sprint(e.Max)
+	if e.Limit == LimitTimeout {
+		maxValue = time.Duration(e.Max).String()
+	}
+	return fmt.Sprintf("%s: %v: %s (max %s)",
+		positionString(e.Filename, e.Line, e.Column), ErrLimitExceeded, e.Limit, maxValue)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
@@ -0,0 +1,336 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+// findState finds the closing delimiter, buffering the input into one lexeme.
+func findState(_ context.Context, l *Lexer) (State, error) {
+	if _, err := l.Find([]string{"}"}); err != nil {
+		return nil, err
+	}
+	l.Emit(l.Lexeme(wordType))
+	return nil, nil
+}
+
+// sleepState sleeps until ctx is done.
+func sleepState(ctx context.Context, _ *Lexer) (State, error) {
+	<-ctx.Done()
+	return nil, nil
+}
+
+func TestLexer_SetLimits(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input  string
+		state  State
+		limits Limits
+		want   *LimitError
+	}{
+		"input runes": {
+			input:  "{ unterminated",
+			state:  StateFn(findState),
+			limits: Limits{MaxInputRunes: 5},
+			want:   &LimitError{Limit: LimitInputRunes, Max: 5, Pos: 6, Column: 6},
+		},
+		"lexeme length": {
+			input:  "{ unterminated",
+			state:  StateFn(findState),
+			limits: Limits{MaxLexemeLength: 3},
+			want:   &LimitError{Limit: LimitLexemeLength, Max: 3, Pos: 4, Column: 4},
+		},
+		"lexemes": {
+			input:  "A B C D",
+			state:  &wordState{},
+			limits: Limits{MaxLexemes: 2},
+			want:   &LimitError{Limit: LimitLexemes, Max: 2, Pos: 4, Column: 4},
+		},
+		"lexeme length advance": {
+			input:  "A Bbbbbb C",
+			state:  &wordState{},
+			limits: Limits{MaxLexemeLength: 3},
+			want:   &LimitError{Limit: LimitLexemeLength, Max: 3, Pos: 6, Column: 6},
+		},
+		"timeout": {
+			input:  "A",
+			state:  StateFn(sleepState),
+			limits: Limits{Timeout: time.Millisecond},
+			want:   &LimitError{Limit: LimitTimeout, Max: int64(time.Millisecond)},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := NewLexer(runeio.NewReader(strings.NewReader(tc.input)), tc.state)
+			l.SetLimits(tc.limits)
+			for range l.Lex(context.Background()) {
+				// Drain the lexemes.
+			}
+			closeWithin(t, l)
+
+			err := l.Err()
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if diff := cmp.Diff(tc.want, limitErr); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// parseNested pushes a node for every lexeme.
+func parseNested(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	if l := p.Peek(); l != nil {
+		p.Push(l.Value)
+		_ = p.Next()
+		return parseNested, nil
+	}
+	return nil, nil
+}
+
+func TestParser_SetLimits(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		limits Limits
+		want   *LimitError
+	}{
+		"depth": {
+			limits: Limits{MaxDepth: 2},
+			want:   &LimitError{Limit: LimitDepth, Max: 2, Pos: 4, Column: 4},
+		},
+		"nodes": {
+			limits: Limits{MaxNodes: 1},
+			want:   &LimitError{Limit: LimitNodes, Max: 1, Pos: 2, Column: 2},
+		},
+		"none": {
+			limits: Limits{MaxDepth: 3, MaxNodes: 3},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lexemes, cancel := testLexer(t, "A B C")
+			defer cancel()
+
+			p := NewParser[string](lexemes)
+			p.SetLimits(tc.limits)
+			_, err := p.Parse(context.Background(), parseNested)
+
+			var limitErr *LimitError
+			if err != nil && !errors.As(err, &limitErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, limitErr); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// parseNestedLoop pushes a node for each lexeme in a single call.
+func parseNestedLoop(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	for l := p.Next(); l != nil; l = p.Next() {
+		p.Push(l.Value)
+	}
+	return nil, nil
+}
+
+func TestParser_SetLimitsLoop(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		limits Limits
+		want   Limit
+	}{
+		"depth": {
+			limits: Limits{MaxDepth: 1},
+			want:   LimitDepth,
+		},
+		"nodes": {
+			limits: Limits{MaxNodes: 1},
+			want:   LimitNodes,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lexemes, cancel := testLexer(t, "A B C D E")
+			defer cancel()
+
+			p := NewParser[string](lexemes)
+			p.SetLimits(tc.limits)
+			root, err := p.Parse(context.Background(), parseNestedLoop)
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := limitErr.Limit, tc.want; got != want {
+				t.Errorf("Limit: want: %v, got: %v", want, got)
+			}
+
+			// The ParseFn stops after the node exceeding the limit.
+			var nodes int
+			for range PreOrder(root) {
+				nodes++
+			}
+			if got, want := nodes, 3; got != want {
+				t.Errorf("nodes: want: %d, got: %d", want, got)
+			}
+		})
+	}
+}
+
+func TestLimitError_Error(t *testing.T) {
+	t.Parallel()
+
+	err := &LimitError{Limit: LimitTimeout, Max: int64(time.Second), Line: 1, Column: 2}
+	if got, want := err.Error(), "2:3: limit exceeded: timeout (max 1s)"; got != want {
+		t.Errorf("Error: want: %q, got: %q", want, got)
+	}
+}
+
+// parseLeftAssoc builds a left-associative tree by pushing a node for each
+// lexeme and rotating it into the place of the previous one.
+func parseLeftAssoc(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	for l := p.Peek(); l != nil; l = p.Peek() {
+		p.Push(l.Value)
+		if _, err := p.RotateLeft(); err != nil {
+			return nil, err
+		}
+		_ = p.Next()
+	}
+	return nil, nil
+}
+
+// parseAdoptLoop builds a deep tree by pushing a node for each lexeme and
+// adopting the previous one.
+func parseAdoptLoop(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	for l := p.Peek(); l != nil; l = p.Peek() {
+		p.Push(l.Value)
+		if _, err := p.AdoptSibling(); err != nil && !errors.Is(err, ErrMissingRequiredNode) {
+			return nil, err
+		}
+		_ = p.Climb()
+		_ = p.Next()
+	}
+	return nil, nil
+}
+
+// parseWrapLoop wraps the root in a new node for each lexeme.
+func parseWrapLoop(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	for l := p.Next(); l != nil; l = p.Next() {
+		_ = p.Wrap(p.Root(), l.Value)
+	}
+	return nil, nil
+}
+
+func TestParser_SetLimitsRestructure(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		parseFn ParseFn[string]
+		limits  Limits
+		want    *LimitError
+	}{
+		"rotate": {
+			parseFn: parseLeftAssoc,
+			limits:  Limits{MaxDepth: 5},
+			want:    &LimitError{Limit: LimitDepth, Max: 5, Pos: 10, Column: 10},
+		},
+		"rotate within limit": {
+			parseFn: parseLeftAssoc,
+			limits:  Limits{MaxDepth: 10},
+		},
+		"adopt": {
+			parseFn: parseAdoptLoop,
+			limits:  Limits{MaxDepth: 5},
+			want:    &LimitError{Limit: LimitDepth, Max: 5, Pos: 10, Column: 10},
+		},
+		"adopt within limit": {
+			parseFn: parseAdoptLoop,
+			limits:  Limits{MaxDepth: 10},
+		},
+		"wrap": {
+			parseFn: parseWrapLoop,
+			limits:  Limits{MaxDepth: 5},
+			want:    &LimitError{Limit: LimitDepth, Max: 5},
+		},
+		"wrap within limit": {
+			parseFn: parseWrapLoop,
+			limits:  Limits{MaxDepth: 10},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lexemes, cancel := testLexer(t, "A B C D E F G H I J")
+			defer cancel()
+
+			p := NewParser[string](lexemes)
+			p.SetLimits(tc.limits)
+			root, err := p.Parse(context.Background(), tc.parseFn)
+
+			var limitErr *LimitError
+			if err != nil && !errors.As(err, &limitErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, limitErr); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+
+			// The tree is never deeper than one level past the limit.
+			var depth int
+			for n := range PreOrder(root) {
+				d := 0
+				for a := n; a.Parent != nil; a = a.Parent {
+					d++
+				}
+				depth = max(depth, d)
+			}
+			if depth > tc.limits.MaxDepth+1 {
+				t.Errorf("depth: want: <= %d, got: %d", tc.limits.MaxDepth+1, depth)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidNumber indicates that a numeric literal is malformed.
+var ErrInvalidNumber = errors.New("invalid number")
+
+// NumberKind is the kind of a numeric literal scanned by ScanNumber.
+type NumberKind int
+
+const (
+	// NumberInt is an integer literal.
+	NumberInt NumberKind = iota + 1
+
+	// NumberFloat is a floating point literal.
+	NumberFloat
+
+	// NumberImag is an imaginary literal.
+	NumberImag
+)
+
+// String implements fmt.Stringer.
+func (k NumberKind) String() string {
+	switch k {
+	case NumberInt:
+		return "int"
+	case NumberFloat:
+		return "float"
+	case NumberImag:
+		return "imag"
+	default:
+		return fmt.Sprintf("NumberKind(%d)", int(k))
+	}
+}
+
+// NumberOptions selects the numeric literal syntax accepted by ScanNumber. The
+// zero value accepts only unsigned decimal integers.
+type NumberOptions struct {
+	// NegativeSign allows a leading '-'.
+	NegativeSign bool
+
+	// Hex allows hexadecimal integers with a 0x or 0X prefix.
+	Hex bool
+
+	// Octal allows octal integers with a 0o or 0O prefix.
+	Octal bool
+
+	// Binary allows binary integers with a 0b or 0B prefix.
+	Binary bool
+
+	// LegacyOctal treats integers with a leading zero (e.g. 0755) as octal.
+	LegacyOctal bool
+
+	// RejectLeadingZeros rejects decimal literals with a leading zero
+	// followed by more digits (e.g. 01) as JSON does.
+	RejectLeadingZeros bool
+
+	// Underscores allows '_' as a separator between digits.
+	Underscores bool
+
+	// Fraction allows a decimal point followed by a fractional part.
+	Fraction bool
+
+	// LeadingDot allows a fraction without an integer part (e.g. .5).
+	// Requires Fraction.
+	LeadingDot bool
+
+	// TrailingDot allows a decimal point without a fractional part (e.g. 1.).
+	// Requires Fraction.
+	TrailingDot bool
+
+	// Exponent allows a decimal exponent (e.g. 1e10).
+	Exponent bool
+
+	// HexFloat allows hexadecimal floating point literals with a mandatory
+	// binary exponent (e.g. 0x1.8p3). Requires Hex.
+	HexFloat bool
+
+	// Imaginary allows an 'i' suffix marking an imaginary literal.
+	Imaginary bool
+
+	// Suffixes is a list of suffixes that may follow the literal (e.g. "u",
+	// "ll"). Suffixes are matched case-insensitively and the longest match
+	// is used. The suffix is included in the lexeme value.
+	Suffixes []string
+}
+
+var (
+	// GoNumbers accepts Go numeric literals.
+	GoNumbers = NumberOptions{
+		Hex:         true,
+		Octal:       true,
+		Binary:      true,
+		LegacyOctal: true,
+		Underscores: true,
+		Fraction:    true,
+		LeadingDot:  true,
+		TrailingDot: true,
+		Exponent:    true,
+		HexFloat:    true,
+		Imaginary:   true,
+	}
+
+	// JSONNumbers accepts JSON numbers.
+	JSONNumbers = NumberOptions{
+		NegativeSign:       true,
+		RejectLeadingZeros: true,
+		Fraction:           true,
+		Exponent:           true,
+	}
+
+	// CNumbers accepts C numeric literals.
+	CNumbers = NumberOptions{
+		Hex:         true,
+		Binary:      true,
+		LegacyOctal: true,
+		Fraction:    true,
+		LeadingDot:  true,
+		TrailingDot: true,
+		Exponent:    true,
+		HexFloat:    true,
+		Suffixes:    []string{"ull", "llu", "ul", "lu", "ll", "u", "l", "f"},
+	}
+)
+
+// ScanNumber scans a numeric literal at the current position, advancing the
+// lexer past it. The literal is added to the current lexeme value. The
+// accepted syntax is selected by opts. The kind of the literal is returned so
+// that the caller can choose a LexemeType.
+//
+// If the literal is malformed, a *LexError wrapping ErrInvalidNumber is
+// returned with the position at which the error was detected.
+func (l *Lexer) ScanNumber(opts NumberOptions) (NumberKind, error) {
+	s := numberScanner{l: l, opts: &opts}
+	return s.scan()
+}
+
+type numberScanner struct {
+	l    *Lexer
+	opts *NumberOptions
+}
+
+func (s *numberScanner) peek() (rune, error) {
+	return s.l.peekRune()
+}
+
+func (s *numberScanner) next() error {
+	_, err := s.l.Advance(1)
+	return err
+}
+
+func (s *numberScanner) errorf(format string, args ...any) error {
+	return s.l.lexError(fmt.Errorf("%w: %s", ErrInvalidNumber, fmt.Sprintf(format, args...)))
+}
+
+func (s *numberScanner) scan() (NumberKind, error) {
+	r, err := s.peek()
+	if err != nil {
+		return 0, err
+	}
+
+	if r == '-' && s.opts.NegativeSign {
+		if err = s.next(); err != nil {
+			return 0, err
+		}
+		if r, err = s.peek(); err != nil {
+			return 0, err
+		}
+	}
+
+	leadingDot := r == '.' && s.opts.Fraction && s.opts.LeadingDot
+	if !isDecimal(r) && !leadingDot {
+		return 0, s.errorf("expected digit, found %s", quoteRune(r))
+	}
+
+	kind := NumberInt
+	base := 10
+	var legacyOctal bool
+	var invalid rune
+	var mantissa int
+
+	if r == '0' {
+		if err = s.next(); err != nil {
+			return 0, err
+		}
+		if r, err = s.peek(); err != nil {
+			return 0, err
+		}
+
+		prefix := base
+		switch lower(r) {
+		case 'x':
+			if s.opts.Hex {
+				prefix = 16
+			}
+		case 'o':
+			if s.opts.Octal {
+				prefix = 8
+			}
+		case 'b':
+			if s.opts.Binary {
+				prefix = 2
+			}
+		}
+
+		if prefix != 10 {
+			if err = s.next(); err != nil {
+				return 0, err
+			}
+			base = prefix
+		} else {
+			mantissa = 1
+			if isDecimal(r) || (r == '_' && s.opts.Underscores) {
+				if s.opts.RejectLeadingZeros {
+					return 0, s.errorf("leading zero")
+				}
+				legacyOctal = s.opts.LegacyOctal
+			}
+		}
+
+		dBase := base
+		if legacyOctal {
+			dBase = 8
+		}
+		n, inv, dErr := s.digits(dBase, true)
+		if dErr != nil {
+			return 0, dErr
+		}
+		mantissa += n
+		invalid = inv
+	} else if !leadingDot {
+		n, inv, dErr := s.digits(base, false)
+		if dErr != nil {
+			return 0, dErr
+		}
+		mantissa += n
+		invalid = inv
+	}
+
+	if base == 2 || base == 8 {
+		if invalid != 0 {
+			return 0, s.errorf("invalid digit %s in %s literal", quoteRune(invalid), baseName(base))
+		}
+	}
+
+	if r, err = s.peek(); err != nil {
+		return 0, err
+	}
+
+	// Fractional part.
+	if r == '.' && s.opts.Fraction && (base == 10 || (base == 16 && s.opts.HexFloat)) {
+		if err = s.next(); err != nil {
+			return 0, err
+		}
+		kind = NumberFloat
+
+		n, _, dErr := s.digits(base, false)
+		if dErr != nil {
+			return 0, dErr
+		}
+		if n == 0 && mantissa > 0 && !s.opts.TrailingDot {
+			return 0, s.errorf("fraction has no digits")
+		}
+		mantissa += n
+	}
+
+	if mantissa == 0 {
+		return 0, s.errorf("%s literal has no digits", baseName(base))
+	}
+
+	// Exponent.
+	if r, err = s.peek(); err != nil {
+		return 0, err
+	}
+	e := lower(r)
+	switch {
+	case (base == 10 && e == 'e' && s.opts.Exponent) || (base == 16 && e == 'p' && s.opts.HexFloat):
+		if err = s.next(); err != nil {
+			return 0, err
+		}
+		kind = NumberFloat
+
+		if r, err = s.peek(); err != nil {
+			return 0, err
+		}
+		if r == '+' || r == '-' {
+			if err = s.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, _, dErr := s.digits(10, false)
+		if dErr != nil {
+			return 0, dErr
+		}
+		if n == 0 {
+			return 0, s.errorf("exponent has no digits")
+		}
+	case base == 16 && kind == NumberFloat:
+		return 0, s.errorf("hexadecimal mantissa requires a 'p' exponent")
+	}
+
+	if legacyOctal && kind == NumberInt && invalid != 0 {
+		return 0, s.errorf("invalid digit %s in octal literal", quoteRune(invalid))
+	}
+
+	// Suffixes.
+	if r, err = s.peek(); err != nil {
+		return 0, err
+	}
+	if r == 'i' && s.opts.Imaginary {
+		if err = s.next(); err != nil {
+			return 0, err
+		}
+		return NumberImag, nil
+	}
+	if err = s.suffix(); err != nil {
+		return 0, err
+	}
+
+	return kind, nil
+}
+
+// digits scans digits in the given base along with any '_' separators and
+// returns the number of digits scanned. For bases less than 10 all decimal
+// digits are scanned and the first digit invalid for the base is returned.
+// If afterPrefix is true, a separator may appear before the first digit.
+func (s *numberScanner) digits(base int, afterPrefix bool) (int, rune, error) {
+	var n int
+	var invalid rune
+	sep := afterPrefix
+	var lastSep bool
+	for {
+		r, err := s.peek()
+		if err != nil {
+			return n, invalid, err
+		}
+
+		switch {
+		case r == '_' && s.opts.Underscores:
+			if !sep {
+				return n, invalid, s.errorf("'_' must separate successive digits")
+			}
+			sep = false
+			lastSep = true
+		case isDigit(r, base):
+			if base < 10 && invalid == 0 && int(r-'0') >= base {
+				invalid = r
+			}
+			n++
+			sep = true
+			lastSep = false
+		default:
+			if lastSep {
+				return n, invalid, s.errorf("'_' must separate successive digits")
+			}
+			return n, invalid, nil
+		}
+
+		if err = s.next(); err != nil {
+			return n, invalid, err
+		}
+	}
+}
+
+// suffix scans the longest matching suffix in opts.Suffixes.
+func (s *numberScanner) suffix() error {
+	var maxLen int
+	for _, sfx := range s.opts.Suffixes {
+		if n := len([]rune(sfx)); n > maxLen {
+			maxLen = n
+		}
+	}
+	if maxLen == 0 {
+		return nil
+	}
+
+	rns, err := s.l.Peek(maxLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("peeking input: %w", err)
+	}
+
+	var match int
+	for _, sfx := range s.opts.Suffixes {
+		n := len([]rune(sfx))
+		if n > match && n <= len(rns) && strings.EqualFold(string(rns[:n]), sfx) {
+			match = n
+		}
+	}
+	if match > 0 {
+		if _, err := s.l.Advance(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDecimal(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isDigit(r rune, base int) bool {
+	if base == 16 {
+		return isDecimal(r) || (lower(r) >= 'a' && lower(r) <= 'f')
+	}
+	return isDecimal(r)
+}
+
+func lower(r rune) rune {
+	return ('a' - 'A') | r
+}
+
+func baseName(base int) string {
+	switch base {
+	case 2:
+		return "binary"
+	case 8:
+		return "octal"
+	case 16:
+		return "hexadecimal"
+	default:
+		return "decimal"
+	}
+}
+
+func quoteRune(r rune) string {
+	if r == eof {
+		return "EOF"
+	}
+	return fmt.Sprintf("%q", r)
+}
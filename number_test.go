@@ -0,0 +1,241 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ianlewis/runeio"
+)
+
+func TestLexer_ScanNumber(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		opts  NumberOptions
+		input string
+		kind  NumberKind
+		value string
+		err   error
+		col   int
+	}{
+		"go int": {
+			opts:  GoNumbers,
+			input: "1234 ",
+			kind:  NumberInt,
+			value: "1234",
+		},
+		"go zero": {
+			opts:  GoNumbers,
+			input: "0)",
+			kind:  NumberInt,
+			value: "0",
+		},
+		"go underscores": {
+			opts:  GoNumbers,
+			input: "1_000_000",
+			kind:  NumberInt,
+			value: "1_000_000",
+		},
+		"go hex": {
+			opts:  GoNumbers,
+			input: "0xDEAD_beef+",
+			kind:  NumberInt,
+			value: "0xDEAD_beef",
+		},
+		"go hex underscore after prefix": {
+			opts:  GoNumbers,
+			input: "0x_1",
+			kind:  NumberInt,
+			value: "0x_1",
+		},
+		"go octal": {
+			opts:  GoNumbers,
+			input: "0o755",
+			kind:  NumberInt,
+			value: "0o755",
+		},
+		"go legacy octal": {
+			opts:  GoNumbers,
+			input: "0755",
+			kind:  NumberInt,
+			value: "0755",
+		},
+		"go legacy octal float": {
+			opts:  GoNumbers,
+			input: "089.5",
+			kind:  NumberFloat,
+			value: "089.5",
+		},
+		"go binary": {
+			opts:  GoNumbers,
+			input: "0b1010",
+			kind:  NumberInt,
+			value: "0b1010",
+		},
+		"go float": {
+			opts:  GoNumbers,
+			input: "3.14159;",
+			kind:  NumberFloat,
+			value: "3.14159",
+		},
+		"go leading dot": {
+			opts:  GoNumbers,
+			input: ".5",
+			kind:  NumberFloat,
+			value: ".5",
+		},
+		"go trailing dot": {
+			opts:  GoNumbers,
+			input: "1.",
+			kind:  NumberFloat,
+			value: "1.",
+		},
+		"go exponent": {
+			opts:  GoNumbers,
+			input: "6.022e+23",
+			kind:  NumberFloat,
+			value: "6.022e+23",
+		},
+		"go hex float": {
+			opts:  GoNumbers,
+			input: "0x1.8p-3",
+			kind:  NumberFloat,
+			value: "0x1.8p-3",
+		},
+		"go imaginary": {
+			opts:  GoNumbers,
+			input: "1.5i",
+			kind:  NumberImag,
+			value: "1.5i",
+		},
+		"json negative": {
+			opts:  JSONNumbers,
+			input: "-12.5E3,",
+			kind:  NumberFloat,
+			value: "-12.5E3",
+		},
+		"json hex not allowed": {
+			opts:  JSONNumbers,
+			input: "0x1",
+			kind:  NumberInt,
+			value: "0",
+		},
+		"c suffix": {
+			opts:  CNumbers,
+			input: "42ULL;",
+			kind:  NumberInt,
+			value: "42ULL",
+		},
+		"c float suffix": {
+			opts:  CNumbers,
+			input: "1.0f",
+			kind:  NumberFloat,
+			value: "1.0f",
+		},
+		"missing exponent digits": {
+			opts:  GoNumbers,
+			input: "1e",
+			err:   ErrInvalidNumber,
+			col:   2,
+		},
+		"missing hex digits": {
+			opts:  GoNumbers,
+			input: "0x",
+			err:   ErrInvalidNumber,
+			col:   2,
+		},
+		"invalid binary digit": {
+			opts:  GoNumbers,
+			input: "0b102",
+			err:   ErrInvalidNumber,
+			col:   5,
+		},
+		"invalid legacy octal digit": {
+			opts:  GoNumbers,
+			input: "0789",
+			err:   ErrInvalidNumber,
+			col:   4,
+		},
+		"hex float requires exponent": {
+			opts:  GoNumbers,
+			input: "0x1.8",
+			err:   ErrInvalidNumber,
+			col:   5,
+		},
+		"double underscore": {
+			opts:  GoNumbers,
+			input: "1__0",
+			err:   ErrInvalidNumber,
+			col:   2,
+		},
+		"trailing underscore": {
+			opts:  GoNumbers,
+			input: "10_",
+			err:   ErrInvalidNumber,
+			col:   3,
+		},
+		"json leading zero": {
+			opts:  JSONNumbers,
+			input: "01",
+			err:   ErrInvalidNumber,
+			col:   1,
+		},
+		"json trailing dot": {
+			opts:  JSONNumbers,
+			input: "1.",
+			err:   ErrInvalidNumber,
+			col:   2,
+		},
+		"not a number": {
+			opts:  GoNumbers,
+			input: "abc",
+			err:   ErrInvalidNumber,
+			col:   0,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			l := NewLexer(runeio.NewReader(strings.NewReader(tc.input)), nil)
+			kind, err := l.ScanNumber(tc.opts)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				var lexErr *LexError
+				if !errors.As(err, &lexErr) {
+					t.Fatalf("error is not a *LexError: %v", err)
+				}
+				if got, want := lexErr.Column, tc.col; got != want {
+					t.Errorf("LexError.Column: want: %v, got: %v", want, got)
+				}
+				return
+			}
+
+			if got, want := kind, tc.kind; got != want {
+				t.Errorf("kind: want: %v, got: %v", want, got)
+			}
+			if got, want := l.Lexeme(unusedType).Value, tc.value; got != want {
+				t.Errorf("lexeme.Value: want: %q, got: %q", want, got)
+			}
+		})
+	}
+}
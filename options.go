@@ -0,0 +1,123 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+// Option configures LexParse, NewLexer or NewParser. Options that don't apply
+// to a Lexer or Parser are ignored by its constructor.
+type Option func(*options)
+
+// options holds the configuration set by Options.
+type options struct {
+	// trivia indicates that the lexer preserves trivia.
+	trivia bool
+
+	// recoverPanics indicates that panics in states and parse functions are
+	// recovered.
+	recoverPanics bool
+
+	// limits are the resource limits for the lexer and parser.
+	limits Limits
+
+	// transforms are applied to the lexemes between the lexer and parser
+	// by LexParse.
+	transforms []Transform
+
+	// bufferSize is the buffer size of the lexer's channels.
+	bufferSize int
+
+	// batchSize is the number of lexemes in each batch.
+	batchSize int
+
+	// interner interns lexeme values.
+	interner Interner
+
+	// source is the source being lexed.
+	source *Source
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTrivia sets whether the lexer preserves trivia. See
+// Lexer.PreserveTrivia.
+func WithTrivia(keep bool) Option {
+	return func(o *options) {
+		o.trivia = keep
+	}
+}
+
+// WithPanicRecovery sets whether panics in states and parse functions are
+// recovered and returned as a *PanicError. See Lexer.RecoverPanics and
+// Parser.RecoverPanics.
+func WithPanicRecovery(recoverPanics bool) Option {
+	return func(o *options) {
+		o.recoverPanics = recoverPanics
+	}
+}
+
+// WithLimits sets the resource limits enforced by the lexer and parser. See
+// Lexer.SetLimits and Parser.SetLimits.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// WithTransforms adds transforms that LexParse applies to the lexemes before
+// they are passed to the parser. It is ignored by NewLexer and NewParser.
+func WithTransforms(transforms ...Transform) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, transforms...)
+	}
+}
+
+// WithBufferSize sets the buffer size of the channel returned by Lexer.Lex or
+// Lexer.LexBatches. A buffer lets the lexer run ahead of the parser instead
+// of the two running in lockstep. The default is an unbuffered channel. A
+// negative n is treated as zero.
+func WithBufferSize(n int) Option {
+	return func(o *options) {
+		o.bufferSize = max(n, 0)
+	}
+}
+
+// WithBatchSize sets the number of lexemes in each batch sent by
+// Lexer.LexBatches. If n is positive, LexParse delivers lexemes from the
+// lexer to the parser in batches. It is ignored by NewParser.
+func WithBatchSize(n int) Option {
+	return func(o *options) {
+		o.batchSize = n
+	}
+}
+
+// WithInterner sets the Interner used by the lexer to intern lexeme values.
+// See Lexer.SetInterner.
+func WithInterner(i Interner) Option {
+	return func(o *options) {
+		o.interner = i
+	}
+}
+
+// WithSource sets the Source being lexed. See Lexer.SetSource.
+func WithSource(src *Source) Option {
+	return func(o *options) {
+		o.source = src
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"fmt"
+	"reflect" //nolint:depguard // reflect is needed to get a func's entry point.
+	"runtime"
+)
+
+// PanicError is returned when a State or ParseFn panics and panic recovery is
+// enabled.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+
+	// Func is the name of the State or ParseFn that panicked.
+	Func string
+
+	// Pos is the position in the input where the panic occurred. For the
+	// parser it is the position of the current lexeme.
+	Pos int
+
+	// Line is the line number in the input where the panic occurred.
+	Line int
+
+	// Column is the column in the line where the panic occurred.
+	Column int
+
+	// Filename is the name of the Source being processed, if any.
+	Filename string
+}
+
+// Error implements error.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: panic in %s: %v", positionString(e.Filename, e.Line, e.Column), e.Func, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// newPanicError returns a PanicError for the recovered value v. It must be
+// called from the deferred function that recovered the panic.
+func newPanicError(v any, fn string) *PanicError {
+	stack := make([]byte, 64<<10)
+	stack = stack[:runtime.Stack(stack, false)]
+	return &PanicError{
+		Value: v,
+		Stack: stack,
+		Func:  fn,
+	}
+}
+
+// funcName returns the name of the function f.
+func funcName(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Sprintf("%T", f)
+	}
+	if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return fmt.Sprintf("%T", f)
+}
+
+// stateName returns the name of the state s.
+func stateName(s State) string {
+	if fs, ok := s.(*fnState); ok {
+		return funcName(fs.f)
+	}
+	return fmt.Sprintf("%T", s)
+}
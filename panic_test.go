@@ -0,0 +1,157 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ianlewis/runeio"
+)
+
+var errPanic = errors.New("panic value")
+
+// panicAfter advances n runes and then panics.
+func panicAfter(n int) State {
+	return StateFn(func(_ context.Context, l *Lexer) (State, error) {
+		if _, err := l.Advance(n); err != nil {
+			return nil, err
+		}
+		panic(errPanic)
+	})
+}
+
+func TestLexer_RecoverPanics(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("ab\ncd")), panicAfter(4))
+	l.RecoverPanics(true)
+	for range l.Lex(context.Background()) {
+		// Drain the lexemes.
+	}
+	closeWithin(t, l)
+
+	var pErr *PanicError
+	if !errors.As(l.Err(), &pErr) {
+		t.Fatalf("unexpected error: %v", l.Err())
+	}
+	if !errors.Is(pErr, errPanic) {
+		t.Errorf("unexpected panic value: %v", pErr.Value)
+	}
+	if got, want := [3]int{pErr.Pos, pErr.Line, pErr.Column}, [3]int{4, 1, 1}; got != want {
+		t.Errorf("position: want: %v, got: %v", want, got)
+	}
+	if !strings.Contains(pErr.Func, "panicAfter") {
+		t.Errorf("Func: want: panicAfter, got: %q", pErr.Func)
+	}
+	if !strings.Contains(string(pErr.Stack), "panicAfter") {
+		t.Errorf("Stack does not contain panicAfter:\n%s", pErr.Stack)
+	}
+}
+
+func parsePanic(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	_ = p.Next()
+	_ = p.Peek()
+	panic("parse panic")
+}
+
+func TestParser_RecoverPanics(t *testing.T) {
+	t.Parallel()
+
+	lexemes, cancel := testLexer(t, "A B")
+	defer cancel()
+
+	p := NewParser[string](lexemes)
+	p.RecoverPanics(true)
+	_, err := p.Parse(context.Background(), parsePanic)
+
+	var pErr *PanicError
+	if !errors.As(err, &pErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := pErr.Value, "parse panic"; got != want {
+		t.Errorf("Value: want: %v, got: %v", want, got)
+	}
+	if got, want := pErr.Pos, 2; got != want {
+		t.Errorf("Pos: want: %v, got: %v", want, got)
+	}
+	if !strings.Contains(pErr.Func, "parsePanic") {
+		t.Errorf("Func: want: parsePanic, got: %q", pErr.Func)
+	}
+	if got, want := pErr.Error(), "1:3: panic in github.com/ianlewis/lexparse.parsePanic: parse panic"; got != want {
+		t.Errorf("Error: want: %q, got: %q", want, got)
+	}
+}
+
+// panicReader is a BufferedRuneReader whose Peek panics.
+type panicReader struct {
+	BufferedRuneReader
+}
+
+func (panicReader) Peek(int) ([]rune, error) {
+	panic(errPanic)
+}
+
+// panicInterner is an Interner that panics.
+type panicInterner struct{}
+
+func (panicInterner) Intern([]byte) string {
+	panic(errPanic)
+}
+
+func TestLexer_RecoverPanicsInCallbacks(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		r    BufferedRuneReader
+		opts []Option
+	}{
+		"reader": {
+			r: panicReader{runeio.NewReader(strings.NewReader("ab"))},
+		},
+		"interner": {
+			r:    runeio.NewReader(strings.NewReader("ab")),
+			opts: []Option{WithInterner(panicInterner{})},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := append([]Option{WithPanicRecovery(true)}, tc.opts...)
+			l := NewLexer(tc.r, &wordState{}, opts...)
+			_ = l.Lex(context.Background())
+			select {
+			case <-l.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatalf("lexer did not finish")
+			}
+			closeWithin(t, l)
+
+			var pErr *PanicError
+			if !errors.As(l.Err(), &pErr) {
+				t.Fatalf("unexpected error: %v", l.Err())
+			}
+			if !errors.Is(pErr, errPanic) {
+				t.Errorf("unexpected panic value: %v", pErr.Value)
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"strings"
 )
 
 // ErrMissingRequiredNode means the tree is missing nodes required to
@@ -38,6 +39,17 @@ type Node[V comparable] struct {
 
 	// Column is the column in the line of the input where the value was found.
 	Column int
+
+	// Leading is the leading trivia of the lexeme from which the node was
+	// created. It is only set if the Lexer preserves trivia.
+	Leading string
+
+	// Trailing is the trailing trivia of the lexeme from which the node was
+	// created. It is only set if the Lexer preserves trivia.
+	Trailing string
+
+	// Source is the source of the lexeme from which the node was created.
+	Source *Source
 }
 
 // ParseFn is the signature for the parsing function used to build the
@@ -52,19 +64,50 @@ type ParseFn[V comparable] func(context.Context, *Parser[V]) (ParseFn[V], error)
 
 // NewParser creates a new Parser that reads from the lexemes channel. The
 // parser is initialized with a root node with an empty value.
-func NewParser[V comparable](lexemes <-chan *Lexeme) *Parser[V] {
+func NewParser[V comparable](lexemes <-chan *Lexeme, opts ...Option) *Parser[V] {
+	return NewSourceParser[V](ChannelSource(lexemes), opts...)
+}
+
+// NewSourceParser creates a new Parser that reads from src. The parser is
+// initialized with a root node with an empty value.
+func NewSourceParser[V comparable](src LexemeSource, opts ...Option) *Parser[V] {
+	o := newOptions(opts)
 	root := &Node[V]{}
 	p := &Parser[V]{
-		lexemes: lexemes,
-		root:    root,
-		node:    root,
+		src:           src,
+		root:          root,
+		node:          root,
+		recoverPanics: o.recoverPanics,
+		limits:        o.limits,
 	}
 	return p
 }
 
-// Parser reads the lexemes produced by a Lexer and builds a parse tree.
+// Reset discards the parse tree and prepares the parser to read from the
+// lexemes channel. Whether panics are recovered and limits are retained.
+func (p *Parser[V]) Reset(lexemes <-chan *Lexeme) {
+	p.ResetSource(ChannelSource(lexemes))
+}
+
+// ResetSource discards the parse tree and prepares the parser to read from
+// src. Whether panics are recovered and limits are retained.
+func (p *Parser[V]) ResetSource(src LexemeSource) {
+	root := &Node[V]{}
+	*p = Parser[V]{
+		src:           src,
+		root:          root,
+		node:          root,
+		recoverPanics: p.recoverPanics,
+		limits:        p.limits,
+	}
+}
+
+// Parser reads the lexemes produced by a Lexer and builds a parse tree. While
+// parsing, the tree should be modified with the Parser's methods, such as
+// Parser.Detach rather than Node.Detach, which keep the Parser's root and
+// current node consistent with the tree.
 type Parser[V comparable] struct {
-	lexemes <-chan *Lexeme
+	src LexemeSource
 
 	// root is the root node of the parse tree.
 	root *Node[V]
@@ -72,8 +115,53 @@ type Parser[V comparable] struct {
 	// node is the current node under processing.
 	node *Node[V]
 
+	// depth is the depth of node in the tree. The root is at depth zero.
+	depth int
+
 	// lexeme is the next lexeme in the stream.
 	lexeme *Lexeme
+
+	// prev is the last lexeme returned by Next whose trivia has not yet been
+	// attached to a node.
+	prev *Lexeme
+
+	// skipped holds the trivia of lexemes returned by Next that were not
+	// attached to a node. It is added to the Leading trivia of the next node.
+	skipped strings.Builder
+
+	// source is the Source of the last lexeme read.
+	source *Source
+
+	// recoverPanics indicates that panics in parse functions are recovered
+	// and returned as a *PanicError.
+	recoverPanics bool
+
+	// limits are the resource limits enforced by the parser.
+	limits Limits
+
+	// nodes is the number of nodes created.
+	nodes int
+
+	// limitErr is the error for the first limit exceeded while creating
+	// nodes.
+	limitErr error
+}
+
+// SetLimits sets the resource limits enforced by the Parser. Parse stops with
+// a *LimitError when a limit is exceeded. The tree limits are checked when
+// nodes are created by Node and Push, and MaxDepth is also checked when
+// methods such as RotateLeft and Wrap move nodes deeper into the tree. The
+// node is still created or moved, Peek and Next return nil from then on so
+// that a ParseFn looping over the lexemes stops, and Parse returns the error
+// once the current ParseFn returns.
+func (p *Parser[V]) SetLimits(limits Limits) {
+	p.limits = limits
+}
+
+// RecoverPanics sets whether a panic in a ParseFn is recovered. A recovered
+// panic stops parsing and is returned by Parse as a *PanicError.
+func (p *Parser[V]) RecoverPanics(recoverPanics bool) {
+	p.recoverPanics = recoverPanics
 }
 
 // Parse builds a parse tree by repeatedly calling parseFn. parseFn
@@ -81,45 +169,106 @@ type Parser[V comparable] struct {
 // an error. The parse tree is built when parseFn returns nil for the
 // parseFn. Parsing can be cancelled by ctx.
 func (p *Parser[V]) Parse(ctx context.Context, parseFn ParseFn[V]) (*Node[V], error) {
+	if timeout := p.limits.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, &LimitError{
+			Limit: LimitTimeout,
+			Max:   int64(timeout),
+		})
+		defer cancel()
+	}
+
 	for {
 		if parseFn == nil {
 			break
 		}
 		select {
 		case <-ctx.Done():
-			//nolint:wrapcheck // We don't need to wrap the context Error.
-			return p.root, ctx.Err()
+			err := p.ctxErr(ctx)
+			setFilename(err, p.source.Name())
+			return p.root, err
 		default:
 		}
 
 		var err error
-		parseFn, err = parseFn(ctx, p)
+		parseFn, err = p.run(ctx, parseFn)
+		if err == nil {
+			err = p.limitErr
+		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
 
+			setFilename(err, p.source.Name())
 			return p.root, err
 		}
 	}
 	return p.root, nil
 }
 
+// ctxErr returns the error for the done context ctx. If the timeout limit was
+// exceeded a *LimitError at the current lexeme's position is returned.
+func (p *Parser[V]) ctxErr(ctx context.Context) error {
+	var limitErr *LimitError
+	if !errors.As(context.Cause(ctx), &limitErr) {
+		//nolint:wrapcheck // We don't need to wrap the context Error.
+		return ctx.Err()
+	}
+	limitErr.Pos, limitErr.Line, limitErr.Column = p.lexemePos()
+	return limitErr
+}
+
+// lexemePos returns the position of the lexeme currently being processed.
+// This is the next lexeme if it has been peeked or otherwise the last lexeme
+// returned by Next.
+func (p *Parser[V]) lexemePos() (int, int, int) {
+	l := p.lexeme
+	if l == nil {
+		l = p.prev
+	}
+	if l == nil {
+		return 0, 0, 0
+	}
+	return l.Pos, l.Line, l.Column
+}
+
+// run calls parseFn and returns the next parse function. If panic recovery is
+// enabled, a panic is returned as a *PanicError.
+func (p *Parser[V]) run(ctx context.Context, parseFn ParseFn[V]) (next ParseFn[V], err error) {
+	if p.recoverPanics {
+		defer func() {
+			if v := recover(); v != nil {
+				pErr := newPanicError(v, funcName(parseFn))
+				pErr.Pos, pErr.Line, pErr.Column = p.lexemePos()
+				next, err = nil, pErr
+			}
+		}()
+	}
+	return parseFn(ctx, p)
+}
+
 // Root returns the root of the parse tree.
 func (p *Parser[V]) Root() *Node[V] {
 	return p.root
 }
 
-// Peek returns the next Lexeme from the lexer without consuming it.
+// Peek returns the next Lexeme from the lexer without consuming it. It
+// returns nil at the end of the input or once a tree limit set with SetLimits
+// has been exceeded.
 func (p *Parser[V]) Peek() *Lexeme {
+	if p.limitErr != nil {
+		return nil
+	}
 	if p.lexeme != nil {
 		return p.lexeme
 	}
-	l, ok := <-p.lexemes
-	if !ok {
+	l := p.src.Next()
+	if l == nil {
 		return nil
 	}
 	p.lexeme = l
+	p.source = l.Source
 	return p.lexeme
 }
 
@@ -127,6 +276,13 @@ func (p *Parser[V]) Peek() *Lexeme {
 func (p *Parser[V]) Next() *Lexeme {
 	l := p.Peek()
 	p.lexeme = nil
+	if l != nil {
+		if p.prev != nil {
+			p.skipped.WriteString(p.prev.Leading)
+			p.skipped.WriteString(p.prev.Trailing)
+		}
+		p.prev = l
+	}
 	return l
 }
 
@@ -141,18 +297,91 @@ func (p *Parser[V]) Pos() *Node[V] {
 func (p *Parser[V]) Push(v V) *Node[V] {
 	n := p.Node(v)
 	p.node = n
+	p.depth++
 	return n
 }
 
 // Node creates a new node at the current lexeme position and adds it as a
-// child to the current node.
+// child to the current node. The trivia of the last lexeme returned by Next is
+// attached to the node if it has not already been attached to another node.
+// The trivia of lexemes returned by Next that did not become nodes, such as
+// punctuation, is prepended to the node's Leading trivia. The values of those
+// lexemes, and the trivia of lexemes skipped after the last node, are not
+// kept in the tree.
 func (p *Parser[V]) Node(v V) *Node[V] {
 	n := p.newNode(v)
+	p.checkLimits(n)
+	if p.prev != nil {
+		p.skipped.WriteString(p.prev.Leading)
+		n.Leading = p.skipped.String()
+		n.Trailing = p.prev.Trailing
+		p.prev = nil
+		p.skipped.Reset()
+	}
 	n.Parent = p.node
 	p.node.Children = append(p.node.Children, n)
 	return n
 }
 
+// checkLimits records a *LimitError if adding n as a child of the current
+// node exceeds Limits.MaxNodes or Limits.MaxDepth. The error is returned by
+// Parse after the current parse function returns.
+func (p *Parser[V]) checkLimits(n *Node[V]) {
+	p.nodes++
+	if p.limitErr != nil {
+		return
+	}
+
+	limit, maxValue := Limit(0), 0
+	if m := p.limits.MaxNodes; m > 0 && p.nodes > m {
+		limit, maxValue = LimitNodes, m
+	}
+	if m := p.limits.MaxDepth; m > 0 && limit == 0 && p.depth+1 > m {
+		limit, maxValue = LimitDepth, m
+	}
+	if limit != 0 {
+		p.setLimitErr(limit, maxValue, n)
+	}
+}
+
+// checkDepth records a *LimitError at the position of the current node if a
+// node in the subtree rooted at n, which is at the given depth, exceeds
+// Limits.MaxDepth. It is used after operations that move a subtree deeper
+// into the tree. Nothing is checked if depth is negative.
+func (p *Parser[V]) checkDepth(n *Node[V], depth int) {
+	m := p.limits.MaxDepth
+	if m <= 0 || depth < 0 || p.limitErr != nil {
+		return
+	}
+	type level struct {
+		n     *Node[V]
+		depth int
+	}
+	stack := []level{{n, depth}}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if c.depth > m {
+			p.setLimitErr(LimitDepth, m, p.node)
+			return
+		}
+		for i := len(c.n.Children) - 1; i >= 0; i-- {
+			stack = append(stack, level{c.n.Children[i], c.depth + 1})
+		}
+	}
+}
+
+// setLimitErr records a *LimitError for limit at the position of n.
+func (p *Parser[V]) setLimitErr(limit Limit, maxValue int, n *Node[V]) {
+	p.limitErr = &LimitError{
+		Limit:  limit,
+		Max:    int64(maxValue),
+		Pos:    n.Pos,
+		Line:   n.Line,
+		Column: n.Column,
+	}
+}
+
 // newNode creates a new node at the current lexeme position and returns it
 // without adding it to the tree.
 func (p *Parser[V]) newNode(v V) *Node[V] {
@@ -168,6 +397,7 @@ func (p *Parser[V]) newNode(v V) *Node[V] {
 		Pos:    pos,
 		Line:   line,
 		Column: col,
+		Source: p.source,
 	}
 }
 
@@ -178,15 +408,18 @@ func (p *Parser[V]) Climb() *Node[V] {
 	n := p.node
 	if p.node.Parent != nil {
 		p.node = p.node.Parent
+		p.depth--
 	}
 	return n
 }
 
 // Replace replaces the current node with a new node with the given value. The
 // old node is removed from the tree and it's value is returned. Can be used to
-// replace the root node.
+// replace the root node. The new node keeps the trivia of the old node.
 func (p *Parser[V]) Replace(v V) V {
 	n := p.newNode(v)
+	n.Leading = p.node.Leading
+	n.Trailing = p.node.Trailing
 
 	// Replace the parent.
 	n.Parent = p.node.Parent
@@ -227,51 +460,18 @@ func (p *Parser[V]) Replace(v V) V {
 // Note that the default empty root node may be rotated if RotateLeft is called
 // on a child node of the root node..
 func (p *Parser[V]) RotateLeft() (*Node[V], error) {
-	// n = node , op = original parent , gp = grand parent
 	n := p.node
-
 	op := n.Parent
-	if op == nil {
-		return nil, ErrMissingRequiredNode
-	}
-
-	gp := op.Parent
-
-	// Remove n from op's Children
-	opChildren := op.Children[:0]
-	for _, x := range op.Children {
-		if x != n {
-			opChildren = append(opChildren, x)
-		}
-	}
-	op.Children = opChildren
-
-	// Add op to n's Children
-	n.Children = append(n.Children, op)
-
-	// Update op's Parent
-	op.Parent = n
-
-	// Update n's Parent
-	n.Parent = gp
-
-	// Replace op with n in gp's Children
-	if gp != nil {
-		gpChildren := gp.Children[:0]
-		for _, x := range gp.Children {
-			if x != op {
-				gpChildren = append(gpChildren, x)
-			} else {
-				gpChildren = append(gpChildren, n)
-			}
-		}
-		gp.Children = gpChildren
+	if err := n.RotateLeft(); err != nil {
+		return nil, err
 	}
 
 	// Update the tree root if needed
 	if p.root == op {
 		p.root = n
 	}
+	p.depth--
+	p.checkDepth(op, p.depth+1)
 
 	return n, nil
 }
@@ -279,41 +479,148 @@ func (p *Parser[V]) RotateLeft() (*Node[V], error) {
 // AdoptSibling moves the current node's previous sibling into the node's
 // child. If no previous sibling exists, ErrMissingRequiredNode is returned.
 func (p *Parser[V]) AdoptSibling() (*Node[V], error) {
-	// n = node , op = original parent , s = sibling
 	n := p.node
-	op := n.Parent
-	var s *Node[V]
+	if err := n.AdoptPrevSibling(); err != nil {
+		return nil, err
+	}
+	p.checkDepth(n.Children[len(n.Children)-1], p.depth+1)
+	return n, nil
+}
 
-	if op == nil {
-		return nil, ErrMissingRequiredNode
+// AdoptNextSibling moves the current node's next sibling into the node's
+// children. If no next sibling exists, ErrMissingRequiredNode is returned.
+func (p *Parser[V]) AdoptNextSibling() (*Node[V], error) {
+	n := p.node
+	if err := n.AdoptNextSibling(); err != nil {
+		return nil, err
 	}
+	p.checkDepth(n.Children[len(n.Children)-1], p.depth+1)
+	return n, nil
+}
 
-	// Find the previous sibling
-	for _, x := range op.Children {
-		if x == n {
-			break
-		}
-		s = x
+// RotateRight moves the last child of the current node to the position of the
+// current node, which becomes the last child of its original last child. The
+// current node is unchanged and the new parent is returned. If the current
+// node has no children, ErrMissingRequiredNode is returned.
+func (p *Parser[V]) RotateRight() (*Node[V], error) {
+	n := p.node
+	c, err := n.RotateRight()
+	if err != nil {
+		return nil, err
 	}
 
-	if s == nil {
-		return nil, ErrMissingRequiredNode
+	if p.root == n {
+		p.root = c
 	}
+	p.depth++
+	p.checkDepth(n, p.depth)
 
-	// Remove s from op's Children
-	opChildren := op.Children[:0]
-	for _, x := range op.Children {
-		if x != s {
-			opChildren = append(opChildren, x)
-		}
+	return c, nil
+}
+
+// Detach removes n from its parent's children. If the current node was in the
+// subtree of n, n's former parent becomes the current node.
+// ErrMissingRequiredNode is returned if n has no parent.
+func (p *Parser[V]) Detach(n *Node[V]) error {
+	parent := n.Parent
+	if err := n.Detach(); err != nil {
+		return err
 	}
-	op.Children = opChildren
+	p.update(parent)
+	return nil
+}
 
-	// Add s to n's Children
-	n.Children = append(n.Children, s)
+// InsertBefore inserts s into the tree as the previous sibling of n. See
+// Node.InsertBefore.
+func (p *Parser[V]) InsertBefore(n, s *Node[V]) error {
+	return p.move(s, func() error { return n.InsertBefore(s) })
+}
 
-	// Update s's Parent
-	s.Parent = n
+// InsertAfter inserts s into the tree as the next sibling of n. See
+// Node.InsertAfter.
+func (p *Parser[V]) InsertAfter(n, s *Node[V]) error {
+	return p.move(s, func() error { return n.InsertAfter(s) })
+}
 
-	return n, nil
+// MoveTo moves n to be the child of parent at index i. See Node.MoveTo.
+func (p *Parser[V]) MoveTo(n, parent *Node[V], i int) error {
+	return p.move(n, func() error { return n.MoveTo(parent, i) })
+}
+
+// Wrap inserts a new node with value v in n's place in the tree and makes n
+// its only child. The new node becomes the root if n was the root. The new
+// node is returned.
+func (p *Parser[V]) Wrap(n *Node[V], v V) *Node[V] {
+	w := n.Wrap(v)
+	p.update(nil)
+	p.checkDepth(w, p.nodeDepth(w))
+	return w
+}
+
+// Unwrap replaces n in its parent's children with n's children. If n was the
+// current node, n's former parent becomes the current node.
+// ErrMissingRequiredNode is returned if n has no parent.
+func (p *Parser[V]) Unwrap(n *Node[V]) error {
+	parent := n.Parent
+	if err := n.Unwrap(); err != nil {
+		return err
+	}
+	p.update(parent)
+	return nil
+}
+
+// SwapWith swaps the positions of n and m in the tree. See Node.SwapWith.
+func (p *Parser[V]) SwapWith(n, m *Node[V]) error {
+	if err := n.SwapWith(m); err != nil {
+		return err
+	}
+	p.update(nil)
+	p.checkDepth(n, p.nodeDepth(n))
+	p.checkDepth(m, p.nodeDepth(m))
+	return nil
+}
+
+// move runs op, which moves n within or into the tree, and updates the parser.
+// If the current node was moved out of the tree, n's former parent becomes the
+// current node.
+func (p *Parser[V]) move(n *Node[V], op func() error) error {
+	if n == nil {
+		return ErrMissingRequiredNode
+	}
+	parent := n.Parent
+	if err := op(); err != nil {
+		return err
+	}
+	p.update(parent)
+	p.checkDepth(n, p.nodeDepth(n))
+	return nil
+}
+
+// update updates the root, current node and depth after the tree was
+// modified. The root is moved to its topmost ancestor. If the current node is
+// no longer in the tree, it is moved to fallback if fallback is still in the
+// tree, or to the root otherwise.
+func (p *Parser[V]) update(fallback *Node[V]) {
+	for p.root.Parent != nil {
+		p.root = p.root.Parent
+	}
+	if !p.root.IsAncestorOf(p.node) {
+		p.node = p.root
+		if fallback != nil && p.root.IsAncestorOf(fallback) {
+			p.node = fallback
+		}
+	}
+	p.depth = p.nodeDepth(p.node)
+}
+
+// nodeDepth returns the depth of n in the tree or -1 if n is not in the tree.
+func (p *Parser[V]) nodeDepth(n *Node[V]) int {
+	var depth int
+	for ; n != p.root; n = n.Parent {
+		if n == nil {
+			return -1
+		}
+		depth++
+	}
+	return depth
 }
@@ -267,6 +267,7 @@ func TestParser_NextPeek(t *testing.T) {
 		Type:   wordType,
 		Value:  "B",
 		Pos:    2,
+		Offset: 2,
 		Line:   0,
 		Column: 2,
 	}
@@ -285,6 +286,7 @@ func TestParser_NextPeek(t *testing.T) {
 		Type:   wordType,
 		Value:  "C",
 		Pos:    4,
+		Offset: 4,
 		Line:   0,
 		Column: 4,
 	}
@@ -659,3 +661,28 @@ func TestParser_RotateLeft_empty(t *testing.T) {
 		t.Fatalf("AdoptSibling: n (-want, +got): \n%s", diff)
 	}
 }
+
+func TestParser_Reset(t *testing.T) {
+	t.Parallel()
+
+	lexemes, cancel := testLexer(t, "A B")
+	defer cancel()
+
+	p := NewParser[string](lexemes)
+	p.Push("A")
+	_ = p.Next()
+
+	lexemes2, cancel2 := testLexer(t, "C")
+	defer cancel2()
+	p.Reset(lexemes2)
+
+	if diff := cmp.Diff(&Node[string]{}, p.Root()); diff != "" {
+		t.Errorf("Root (-want +got):\n%s", diff)
+	}
+	if got, want := p.Pos(), p.Root(); got != want {
+		t.Errorf("Pos: want: root, got: %v", got.Value)
+	}
+	if got := p.Next(); got == nil || got.Value != "C" {
+		t.Errorf("Next: want: C, got: %v", got)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import "sync"
+
+// lexemePool holds released Lexemes for reuse.
+var lexemePool = sync.Pool{
+	New: func() any {
+		return new(Lexeme)
+	},
+}
+
+// NewLexeme returns a zeroed Lexeme, reusing one returned by Release if one
+// is available.
+func NewLexeme() *Lexeme {
+	//nolint:forcetypeassert // The pool only holds *Lexeme.
+	return lexemePool.Get().(*Lexeme)
+}
+
+// Release returns the lexeme to the pool used by NewLexeme and the Lexer so
+// that it can be reused for a later lexeme. Releasing lexemes is optional but
+// avoids an allocation per lexeme. The lexeme must not be used after it is
+// released. In particular, a Parser holds on to the last lexeme returned by
+// Next until the next call to Next or Node.
+func (l *Lexeme) Release() {
+	if l == nil {
+		return
+	}
+	*l = Lexeme{}
+	lexemePool.Put(l)
+}
+
+// Interner returns canonical strings for lexeme values so that values that
+// occur repeatedly, such as keywords and identifiers, share one string rather
+// than each lexeme allocating its own.
+type Interner interface {
+	// Intern returns a string equal to b. The Interner must not retain b.
+	Intern(b []byte) string
+}
+
+// InternFunc is a function that implements Interner.
+type InternFunc func(b []byte) string
+
+// Intern implements Interner.Intern.
+func (f InternFunc) Intern(b []byte) string {
+	return f(b)
+}
+
+// InternTable is an Interner that stores each value it has seen in a map. The
+// zero value is an empty table with no size limit. An InternTable is safe for
+// concurrent use and may be shared by multiple Lexers.
+type InternTable struct {
+	mu sync.Mutex
+
+	// m maps each interned value to itself.
+	m map[string]string
+
+	// maxEntries is the maximum number of values stored.
+	maxEntries int
+}
+
+// NewInternTable returns a new InternTable holding at most maxEntries values.
+// Values seen once the table is full are returned without being stored. If
+// maxEntries is zero or less the table size is not limited. The given values,
+// for example the keywords of a language, are added to the table.
+func NewInternTable(maxEntries int, values ...string) *InternTable {
+	t := &InternTable{
+		m:          make(map[string]string, len(values)),
+		maxEntries: maxEntries,
+	}
+	for _, v := range values {
+		t.m[v] = v
+	}
+	return t
+}
+
+// Intern implements Interner.Intern.
+func (t *InternTable) Intern(b []byte) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// NOTE: The compiler doesn't allocate a string for the lookup.
+	if s, ok := t.m[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if t.maxEntries <= 0 || len(t.m) < t.maxEntries {
+		if t.m == nil {
+			t.m = map[string]string{}
+		}
+		t.m[s] = s
+	}
+	return s
+}
+
+// Len returns the number of values in the table.
+func (t *InternTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.m)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+func TestLexeme_Release(t *testing.T) {
+	t.Parallel()
+
+	lexeme := NewLexeme()
+	lexeme.Type = wordType
+	lexeme.Value = "foo"
+	lexeme.Release()
+	if diff := cmp.Diff(&Lexeme{}, lexeme); diff != "" {
+		t.Errorf("unexpected lexeme (-want +got):\n%s", diff)
+	}
+
+	// Releasing nil is a no-op.
+	var nilLexeme *Lexeme
+	nilLexeme.Release()
+}
+
+func TestInternTable(t *testing.T) {
+	t.Parallel()
+
+	t.Run("interned", func(t *testing.T) {
+		t.Parallel()
+
+		var table InternTable
+		a := table.Intern([]byte("foo"))
+		b := table.Intern([]byte("foo"))
+		if a != "foo" || b != "foo" {
+			t.Fatalf("Intern: want: %q, got: %q, %q", "foo", a, b)
+		}
+		if got, want := table.Len(), 1; got != want {
+			t.Errorf("Len: want: %d, got: %d", want, got)
+		}
+	})
+
+	t.Run("preloaded", func(t *testing.T) {
+		t.Parallel()
+
+		table := NewInternTable(0, "if")
+		if got, want := table.Intern([]byte("if")), "if"; got != want {
+			t.Errorf("Intern: want: %q, got: %q", want, got)
+		}
+		if got, want := table.Len(), 1; got != want {
+			t.Errorf("Len: want: %d, got: %d", want, got)
+		}
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		t.Parallel()
+
+		table := NewInternTable(1)
+		table.Intern([]byte("a"))
+		if got, want := table.Intern([]byte("b")), "b"; got != want {
+			t.Errorf("Intern: want: %q, got: %q", want, got)
+		}
+		if got, want := table.Len(), 1; got != want {
+			t.Errorf("Len: want: %d, got: %d", want, got)
+		}
+	})
+}
+
+// emitWordState emits space separated words using EmitValue.
+type emitWordState struct{}
+
+func (emitWordState) Run(_ context.Context, l *Lexer) (State, error) {
+	if _, err := l.Find([]string{" "}); err != nil {
+		return nil, err
+	}
+	lexeme := l.Lexeme(wordType)
+	l.EmitValue(*lexeme)
+	lexeme.Release()
+	if _, err := l.Discard(1); err != nil {
+		return nil, err
+	}
+	return emitWordState{}, nil
+}
+
+func TestLexer_EmitValue(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("a bb ")), emitWordState{})
+	var got []*Lexeme
+	for lexeme := range l.Lex(context.Background()) {
+		got = append(got, lexeme)
+	}
+	want := []*Lexeme{
+		{Type: wordType, Value: "a"},
+		{Type: wordType, Value: "bb", Pos: 2, Offset: 2, Column: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+}
+
+//nolint:paralleltest // AllocsPerRun can't be used in parallel tests.
+func TestLexer_allocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocations are not reliable with the race detector")
+	}
+
+	input := strings.Repeat("foo bar baz ", 10000)
+	l := NewLexer(runeio.NewReader(strings.NewReader(input)), emitWordState{},
+		WithInterner(NewInternTable(0)),
+	)
+	defer l.Close()
+	lexemes := l.Lex(context.Background())
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		lexeme := <-lexemes
+		lexeme.Release()
+	})
+	if allocs != 0 {
+		t.Errorf("allocations per lexeme: want: 0, got: %v", allocs)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrSExprSyntax indicates that an S-expression is malformed.
+var ErrSExprSyntax = errors.New("s-expression syntax error")
+
+// PrintFormat is the output format used by Fprint.
+type PrintFormat int
+
+const (
+	// Outline prints one node per line indented by depth.
+	Outline PrintFormat = iota
+
+	// SExpr prints the tree as an S-expression on a single line. Leaf nodes
+	// are printed as atoms and other nodes as a list of the node's value
+	// followed by its children, e.g. (+ 1 (* 2 3)).
+	SExpr
+)
+
+// truncated is printed in place of nodes omitted due to MaxDepth or MaxWidth.
+const truncated = "..."
+
+// PrintOptions configures Fprint.
+type PrintOptions[V comparable] struct {
+	// Format is the output format.
+	Format PrintFormat
+
+	// Value formats a node value. If nil, values are formatted with
+	// fmt.Sprint. In the SExpr format, values that are not valid atoms are
+	// quoted.
+	Value func(V) string
+
+	// Positions annotates each node with its one-indexed Line:Column. In the
+	// SExpr format the annotation follows the value, e.g. x@1:5.
+	Positions bool
+
+	// Indent is the indentation per level in the Outline format. If empty,
+	// two spaces are used.
+	Indent string
+
+	// MaxDepth is the maximum depth of nodes printed. The root is at depth
+	// zero. Omitted children are printed as "...". Zero means no limit.
+	MaxDepth int
+
+	// MaxWidth is the maximum number of children printed for each node.
+	// Omitted children are printed as "...". Zero means no limit.
+	MaxWidth int
+}
+
+// Fprint writes the tree rooted at n to w in the format given by opts.
+func Fprint[V comparable](w io.Writer, n *Node[V], opts PrintOptions[V]) error {
+	p := printer[V]{opts: opts}
+	if p.opts.Indent == "" {
+		p.opts.Indent = "  "
+	}
+	if n != nil {
+		if opts.Format == SExpr {
+			p.sexpr(n, 0)
+			p.b.WriteByte('\n')
+		} else {
+			p.outline(n, 0)
+		}
+	}
+	_, err := io.WriteString(w, p.b.String())
+	return err
+}
+
+type printer[V comparable] struct {
+	opts PrintOptions[V]
+	b    strings.Builder
+}
+
+func (p *printer[V]) value(n *Node[V]) string {
+	if p.opts.Value != nil {
+		return p.opts.Value(n.Value)
+	}
+	return fmt.Sprint(n.Value)
+}
+
+func (p *printer[V]) pos(n *Node[V]) string {
+	return fmt.Sprintf("@%d:%d", n.Line+1, n.Column+1)
+}
+
+// children returns the children of n to print and whether any were omitted.
+func (p *printer[V]) children(n *Node[V], depth int) ([]*Node[V], bool) {
+	if len(n.Children) == 0 {
+		return nil, false
+	}
+	if p.opts.MaxDepth > 0 && depth >= p.opts.MaxDepth {
+		return nil, true
+	}
+	if p.opts.MaxWidth > 0 && len(n.Children) > p.opts.MaxWidth {
+		return n.Children[:p.opts.MaxWidth], true
+	}
+	return n.Children, false
+}
+
+func (p *printer[V]) outline(n *Node[V], depth int) {
+	indent := strings.Repeat(p.opts.Indent, depth)
+	p.b.WriteString(indent)
+	if n == nil {
+		p.b.WriteString("<nil>\n")
+		return
+	}
+	p.b.WriteString(p.value(n))
+	if p.opts.Positions {
+		p.b.WriteByte(' ')
+		p.b.WriteString(p.pos(n))
+	}
+	p.b.WriteByte('\n')
+
+	children, omitted := p.children(n, depth)
+	for _, c := range children {
+		p.outline(c, depth+1)
+	}
+	if omitted {
+		p.b.WriteString(indent + p.opts.Indent + truncated + "\n")
+	}
+}
+
+func (p *printer[V]) sexpr(n *Node[V], depth int) {
+	if n == nil {
+		p.b.WriteString("()")
+		return
+	}
+	children, omitted := p.children(n, depth)
+	if len(children) > 0 || omitted {
+		p.b.WriteByte('(')
+	}
+	p.b.WriteString(atom(p.value(n)))
+	if p.opts.Positions {
+		p.b.WriteString(p.pos(n))
+	}
+	for _, c := range children {
+		p.b.WriteByte(' ')
+		p.sexpr(c, depth+1)
+	}
+	if omitted {
+		p.b.WriteString(" " + truncated)
+	}
+	if len(children) > 0 || omitted {
+		p.b.WriteByte(')')
+	}
+}
+
+// atom returns s as an S-expression atom, quoting it if necessary.
+func atom(s string) string {
+	if s == "" {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if !isAtomRune(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func isAtomRune(r rune) bool {
+	return r != '(' && r != ')' && r != '"' && r != '@' && unicode.IsPrint(r) && !unicode.IsSpace(r)
+}
+
+// ParseSExpr parses an S-expression in the form written by Fprint with the
+// SExpr format and returns the tree. Atoms are converted to values using
+// parse. Position annotations set the node's Line and Column but not its Pos.
+// Truncated output written using MaxDepth or MaxWidth cannot be parsed back
+// into the original tree.
+func ParseSExpr[V comparable](s string, parse func(string) (V, error)) (*Node[V], error) {
+	p := sexprParser[V]{s: s, parse: parse}
+	p.space()
+	n, err := p.expr(nil)
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q after expression", p.s[p.pos:])
+	}
+	return n, nil
+}
+
+type sexprParser[V comparable] struct {
+	s     string
+	pos   int
+	parse func(string) (V, error)
+}
+
+func (p *sexprParser[V]) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: offset %d: %s", ErrSExprSyntax, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *sexprParser[V]) space() {
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+func (p *sexprParser[V]) expr(parent *Node[V]) (*Node[V], error) {
+	if !strings.HasPrefix(p.s[p.pos:], "(") {
+		return p.atom(parent)
+	}
+	p.pos++
+	p.space()
+	if strings.HasPrefix(p.s[p.pos:], ")") {
+		return nil, p.errorf("empty list")
+	}
+	n, err := p.atom(parent)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.space()
+		if p.pos >= len(p.s) {
+			return nil, p.errorf("expected ')'")
+		}
+		if p.s[p.pos] == ')' {
+			p.pos++
+			return n, nil
+		}
+		c, err := p.expr(n)
+		if err != nil {
+			return nil, err
+		}
+		n.Children = append(n.Children, c)
+	}
+}
+
+func (p *sexprParser[V]) atom(parent *Node[V]) (*Node[V], error) {
+	start := p.pos
+	var text string
+	if strings.HasPrefix(p.s[p.pos:], `"`) {
+		q, err := strconv.QuotedPrefix(p.s[p.pos:])
+		if err != nil {
+			return nil, p.errorf("invalid quoted atom")
+		}
+		p.pos += len(q)
+		text, _ = strconv.Unquote(q)
+	} else {
+		for p.pos < len(p.s) {
+			r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			if !isAtomRune(r) {
+				break
+			}
+			p.pos += size
+		}
+		if p.pos == start {
+			return nil, p.errorf("expected atom")
+		}
+		text = p.s[start:p.pos]
+	}
+
+	v, err := p.parse(text)
+	if err != nil {
+		p.pos = start
+		return nil, fmt.Errorf("%w: offset %d: %w", ErrSExprSyntax, p.pos, err)
+	}
+	n := &Node[V]{Parent: parent, Value: v}
+
+	if strings.HasPrefix(p.s[p.pos:], "@") {
+		p.pos++
+		l := p.number()
+		if l < 1 || !strings.HasPrefix(p.s[p.pos:], ":") {
+			return nil, p.errorf("expected line:column")
+		}
+		p.pos++
+		c := p.number()
+		if c < 1 {
+			return nil, p.errorf("expected column")
+		}
+		n.Line, n.Column = l-1, c-1
+	}
+	return n, nil
+}
+
+// number parses a decimal number and returns it or -1 if there is none.
+func (p *sexprParser[V]) number() int {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	i, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return -1
+	}
+	return i
+}
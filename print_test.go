@@ -0,0 +1,176 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func parseString(s string) (string, error) {
+	return s, nil
+}
+
+func TestFprint(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		opts PrintOptions[string]
+		want string
+	}{
+		"outline": {
+			want: "R\n  A\n    D\n    E\n  B\n  C\n",
+		},
+		"outline positions": {
+			opts: PrintOptions[string]{Positions: true, MaxDepth: 1},
+			want: "R @1:1\n  A @2:3\n    ...\n  B @1:1\n  C @1:1\n",
+		},
+		"outline indent": {
+			opts: PrintOptions[string]{Indent: "\t", MaxWidth: 1},
+			want: "R\n\tA\n\t\tD\n\t\t...\n\t...\n",
+		},
+		"sexpr": {
+			opts: PrintOptions[string]{Format: SExpr},
+			want: "(R (A D E) B C)\n",
+		},
+		"sexpr value": {
+			opts: PrintOptions[string]{
+				Format: SExpr,
+				Value:  func(v string) string { return v + " " + v },
+			},
+			want: `("R R" ("A A" "D D" "E E") "B B" "C C")` + "\n",
+		},
+		"sexpr positions": {
+			opts: PrintOptions[string]{Format: SExpr, Positions: true, MaxWidth: 2},
+			want: "(R@1:1 (A@2:3 D@1:1 E@1:1) B@1:1 ...)\n",
+		},
+		"sexpr depth": {
+			opts: PrintOptions[string]{Format: SExpr, MaxDepth: 1},
+			want: "(R (A ...) B C)\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root := mutationTree()
+			a := find(root, "A")
+			a.Line, a.Column = 1, 2
+
+			var b strings.Builder
+			if err := Fprint(&b, root, tc.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, b.String()); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSExpr(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input string
+		want  *Node[string]
+		err   error
+	}{
+		"atom": {
+			input: "x",
+			want:  &Node[string]{Value: "x"},
+		},
+		"tree": {
+			input: ` (R (A D E) B "C C") `,
+			want: addParent(&Node[string]{
+				Value: "R",
+				Children: []*Node[string]{
+					{
+						Value: "A",
+						Children: []*Node[string]{
+							{Value: "D"},
+							{Value: "E"},
+						},
+					},
+					{Value: "B"},
+					{Value: "C C"},
+				},
+			}),
+		},
+		"positions": {
+			input: `("" x@2:3)`,
+			want: newTree(
+				&Node[string]{Value: "x", Line: 1, Column: 2},
+			),
+		},
+		"empty list": {
+			input: "()",
+			err:   ErrSExprSyntax,
+		},
+		"unterminated": {
+			input: "(R A",
+			err:   ErrSExprSyntax,
+		},
+		"trailing": {
+			input: "(R A) B",
+			err:   ErrSExprSyntax,
+		},
+		"bad position": {
+			input: "x@1",
+			err:   ErrSExprSyntax,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseSExpr(tc.input, parseString)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected tree (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSExpr_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	root := mutationTree()
+	find(root, "E").Value = `e "quoted" (value)`
+	find(root, "B").Line = 3
+
+	var b strings.Builder
+	if err := Fprint(&b, root, PrintOptions[string]{Format: SExpr, Positions: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := ParseSExpr(b.String(), parseString)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !Equal(root, got, EqualOptions{}) {
+		t.Errorf("unexpected tree: want: %s, got: %s", shape(root), shape(got))
+	}
+	checkParents(t, got)
+}
@@ -0,0 +1,352 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	// ErrQuerySyntax indicates that a query is malformed.
+	ErrQuerySyntax = errors.New("query syntax error")
+
+	// ErrUnknownMatcher indicates that a query refers to a matcher that has
+	// not been registered.
+	ErrUnknownMatcher = errors.New("unknown matcher")
+)
+
+// Matcher reports whether a node value matches. arg is the quoted argument
+// given to the matcher in the query (e.g. "x" in name("x")) or the empty
+// string if no argument was given.
+type Matcher[V comparable] func(v V, arg string) bool
+
+// Matchers is a registry of named matchers that can be referred to in a query.
+type Matchers[V comparable] map[string]Matcher[V]
+
+// Match is a node selected by a query along with the captured nodes.
+type Match[V comparable] struct {
+	// Node is the selected node.
+	Node *Node[V]
+
+	// Captures maps capture names to the nodes captured while selecting
+	// Node. It is nil if the query has no captures.
+	Captures map[string]*Node[V]
+}
+
+// Query is a compiled query over a tree of nodes. Queries are similar in
+// spirit to XPath. A query is a sequence of steps, each of which selects
+// nodes relative to the nodes selected by the previous step, starting with
+// the node passed to Select:
+//
+//	/test       selects children of the current nodes matching test.
+//	//test      selects descendants of the current nodes matching test.
+//
+// A test is either '*', which matches any node, or the name of a registered
+// matcher with an optional quoted argument (e.g. ident or ident("x")). Each
+// step may be followed by any number of predicates in square brackets which
+// further filter the nodes:
+//
+//	[test]      keeps nodes matching the test.
+//	[n]         keeps the n-th node (zero indexed). Negative indexes count
+//	            back from the last node.
+//	[last()]    keeps the last node.
+//
+// Positions are relative to the nodes selected from the same parent. Finally,
+// a step may end with a capture name (e.g. @name) which records the node
+// selected by the step in the Match.
+//
+// For example, "//call@c/arg[0]" selects the first argument of every call
+// and captures the call as "c".
+type Query[V comparable] struct {
+	steps []queryStep[V]
+}
+
+type queryStep[V comparable] struct {
+	descendant bool
+	test       *queryTest[V]
+	preds      []queryPred[V]
+	capture    string
+}
+
+type queryTest[V comparable] struct {
+	m   Matcher[V]
+	arg string
+}
+
+func (t *queryTest[V]) match(n *Node[V]) bool {
+	return t == nil || t.m(n.Value, t.arg)
+}
+
+// queryPred is a predicate. If test is nil, the predicate is positional.
+type queryPred[V comparable] struct {
+	test  *queryTest[V]
+	index int
+	last  bool
+}
+
+// CompileQuery compiles the query q using the given matchers.
+func CompileQuery[V comparable](q string, matchers Matchers[V]) (*Query[V], error) {
+	p := queryParser[V]{q: q, matchers: matchers}
+	return p.parse()
+}
+
+// Select returns the nodes in the tree rooted at n selected by the query.
+// Each node is returned once, in the order in which it was first selected.
+func (q *Query[V]) Select(n *Node[V]) []Match[V] {
+	if n == nil {
+		return nil
+	}
+
+	cur := []Match[V]{{Node: n}}
+	for i := range q.steps {
+		step := &q.steps[i]
+		var next []Match[V]
+		seen := map[*Node[V]]bool{}
+		for _, m := range cur {
+			parents := []*Node[V]{m.Node}
+			if step.descendant {
+				parents = parents[:0]
+				for p := range PreOrder(m.Node) {
+					parents = append(parents, p)
+				}
+			}
+			for _, p := range parents {
+				for _, c := range step.apply(p) {
+					if seen[c] {
+						continue
+					}
+					seen[c] = true
+					next = append(next, Match[V]{
+						Node:     c,
+						Captures: capture(m.Captures, step.capture, c),
+					})
+				}
+			}
+		}
+		cur = next
+	}
+	return cur
+}
+
+// Select compiles the query q using the given matchers and returns the nodes
+// selected in the tree rooted at n.
+func Select[V comparable](n *Node[V], q string, matchers Matchers[V]) ([]Match[V], error) {
+	query, err := CompileQuery(q, matchers)
+	if err != nil {
+		return nil, err
+	}
+	return query.Select(n), nil
+}
+
+// apply returns the children of p selected by the step.
+func (s *queryStep[V]) apply(p *Node[V]) []*Node[V] {
+	var nodes []*Node[V]
+	for _, c := range p.Children {
+		if c != nil && s.test.match(c) {
+			nodes = append(nodes, c)
+		}
+	}
+
+	for _, pred := range s.preds {
+		if len(nodes) == 0 {
+			return nil
+		}
+		if pred.test != nil {
+			filtered := nodes[:0]
+			for _, n := range nodes {
+				if pred.test.match(n) {
+					filtered = append(filtered, n)
+				}
+			}
+			nodes = filtered
+			continue
+		}
+
+		i := pred.index
+		if pred.last {
+			i = len(nodes) - 1
+		} else if i < 0 {
+			i += len(nodes)
+		}
+		if i < 0 || i >= len(nodes) {
+			return nil
+		}
+		nodes = []*Node[V]{nodes[i]}
+	}
+	return nodes
+}
+
+// capture returns a copy of caps with name mapped to n. caps is returned
+// unchanged if name is empty.
+func capture[V comparable](caps map[string]*Node[V], name string, n *Node[V]) map[string]*Node[V] {
+	if name == "" {
+		return caps
+	}
+	c := make(map[string]*Node[V], len(caps)+1)
+	for k, v := range caps {
+		c[k] = v
+	}
+	c[name] = n
+	return c
+}
+
+type queryParser[V comparable] struct {
+	q        string
+	pos      int
+	matchers Matchers[V]
+}
+
+func (p *queryParser[V]) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: offset %d: %s", ErrQuerySyntax, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser[V]) parse() (*Query[V], error) {
+	var q Query[V]
+	if p.q == "" {
+		return nil, p.errorf("empty query")
+	}
+	for p.pos < len(p.q) {
+		step, err := p.step()
+		if err != nil {
+			return nil, err
+		}
+		q.steps = append(q.steps, step)
+	}
+	return &q, nil
+}
+
+func (p *queryParser[V]) step() (queryStep[V], error) {
+	var s queryStep[V]
+	if !p.accept("/") {
+		return s, p.errorf("expected '/'")
+	}
+	s.descendant = p.accept("/")
+
+	if !p.accept("*") {
+		t, err := p.test()
+		if err != nil {
+			return s, err
+		}
+		s.test = t
+	}
+
+	for p.accept("[") {
+		pred, err := p.pred()
+		if err != nil {
+			return s, err
+		}
+		if !p.accept("]") {
+			return s, p.errorf("expected ']'")
+		}
+		s.preds = append(s.preds, pred)
+	}
+
+	if p.accept("@") {
+		s.capture = p.ident()
+		if s.capture == "" {
+			return s, p.errorf("expected capture name")
+		}
+	}
+	return s, nil
+}
+
+func (p *queryParser[V]) pred() (queryPred[V], error) {
+	var pred queryPred[V]
+
+	if p.accept("last()") {
+		pred.last = true
+		return pred, nil
+	}
+
+	start := p.pos
+	if p.pos < len(p.q) && p.q[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.q) && p.q[p.pos] >= '0' && p.q[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos > start {
+		num := p.q[start:p.pos]
+		i, err := strconv.Atoi(num)
+		if err != nil {
+			p.pos = start
+			return pred, p.errorf("invalid index %q", num)
+		}
+		pred.index = i
+		return pred, nil
+	}
+
+	t, err := p.test()
+	if err != nil {
+		return pred, err
+	}
+	pred.test = t
+	return pred, nil
+}
+
+func (p *queryParser[V]) test() (*queryTest[V], error) {
+	start := p.pos
+	name := p.ident()
+	if name == "" {
+		return nil, p.errorf("expected '*' or matcher name")
+	}
+	m, ok := p.matchers[name]
+	if !ok {
+		p.pos = start
+		return nil, fmt.Errorf("%w: offset %d: %q", ErrUnknownMatcher, p.pos, name)
+	}
+
+	t := &queryTest[V]{m: m}
+	if p.accept("(") {
+		arg, err := strconv.QuotedPrefix(p.q[p.pos:])
+		if err != nil {
+			return nil, p.errorf("expected quoted argument")
+		}
+		p.pos += len(arg)
+		if t.arg, err = strconv.Unquote(arg); err != nil {
+			return nil, p.errorf("invalid argument %s", arg)
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected ')'")
+		}
+	}
+	return t, nil
+}
+
+func (p *queryParser[V]) ident() string {
+	start := p.pos
+	for _, r := range p.q[p.pos:] {
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		if p.pos == start && !unicode.IsLetter(r) && r != '_' {
+			break
+		}
+		p.pos += len(string(r))
+	}
+	return p.q[start:p.pos]
+}
+
+func (p *queryParser[V]) accept(s string) bool {
+	if strings.HasPrefix(p.q[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
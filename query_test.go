@@ -0,0 +1,181 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// kindMatcher matches values of the form "kind:name".
+func kindMatcher(kind string) Matcher[string] {
+	return func(v, arg string) bool {
+		k, name, _ := strings.Cut(v, ":")
+		return k == kind && (arg == "" || arg == name)
+	}
+}
+
+var queryMatchers = Matchers[string]{
+	"call": kindMatcher("call"),
+	"arg":  kindMatcher("arg"),
+}
+
+// queryTree returns a tree representing f(a, b); g(c, h(d)).
+func queryTree() *Node[string] {
+	return newTree(
+		&Node[string]{
+			Value: "call:f",
+			Children: []*Node[string]{
+				{Value: "arg:a"},
+				{Value: "arg:b"},
+			},
+		},
+		&Node[string]{
+			Value: "call:g",
+			Children: []*Node[string]{
+				{Value: "arg:c"},
+				{
+					Value: "call:h",
+					Children: []*Node[string]{
+						{Value: "arg:d"},
+					},
+				},
+			},
+		},
+	)
+}
+
+func TestQuery_Select(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query string
+		want  []string
+	}{
+		"children": {
+			query: "/*",
+			want:  []string{"call:f", "call:g"},
+		},
+		"child matcher": {
+			query: "/call/arg",
+			want:  []string{"arg:a", "arg:b", "arg:c"},
+		},
+		"descendants": {
+			query: "//arg",
+			want:  []string{"arg:a", "arg:b", "arg:c", "arg:d"},
+		},
+		"matcher argument": {
+			query: `//call("h")/*`,
+			want:  []string{"arg:d"},
+		},
+		"position": {
+			query: "//call/*[0]",
+			want:  []string{"arg:a", "arg:c", "arg:d"},
+		},
+		"last": {
+			query: "//call/*[last()]",
+			want:  []string{"arg:b", "call:h", "arg:d"},
+		},
+		"negative position": {
+			query: "/call/arg[-2]",
+			want:  []string{"arg:a"},
+		},
+		"out of range": {
+			query: "/call/arg[5]",
+		},
+		"filter then position": {
+			query: "//*[arg][last()]",
+			want:  []string{"arg:b", "arg:c", "arg:d"},
+		},
+		"descendant of descendant": {
+			query: "//call//arg",
+			want:  []string{"arg:a", "arg:b", "arg:c", "arg:d"},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			matches, err := Select(queryTree(), tc.query, queryMatchers)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.Node.Value)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected nodes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestQuery_captures(t *testing.T) {
+	t.Parallel()
+
+	q, err := CompileQuery("//call@c/arg[0]@first", queryMatchers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got []map[string]string
+	for _, m := range q.Select(queryTree()) {
+		caps := map[string]string{}
+		for k, n := range m.Captures {
+			caps[k] = n.Value
+		}
+		got = append(got, caps)
+	}
+
+	want := []map[string]string{
+		{"c": "call:f", "first": "arg:a"},
+		{"c": "call:g", "first": "arg:c"},
+		{"c": "call:h", "first": "arg:d"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected captures (-want +got):\n%s", diff)
+	}
+}
+
+func TestCompileQuery_errors(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]error{
+		"":             ErrQuerySyntax,
+		"call":         ErrQuerySyntax,
+		"/":            ErrQuerySyntax,
+		"/call[0":      ErrQuerySyntax,
+		"/call[-]":     ErrQuerySyntax,
+		"/call@":       ErrQuerySyntax,
+		`/call("x"`:    ErrQuerySyntax,
+		"/call(x)":     ErrQuerySyntax,
+		"/unknown":     ErrUnknownMatcher,
+		"/*[unknown]":  ErrUnknownMatcher,
+		"/call/arg[0]": nil,
+	}
+
+	for q, want := range testCases {
+		if _, err := CompileQuery(q, queryMatchers); !errors.Is(err, want) {
+			t.Errorf("CompileQuery(%q): want: %v, got: %v", q, want, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+var (
+	// ErrBufferFull is returned by BufferedRuneReader.Peek when more runes
+	// are requested than fit in the reader's buffer.
+	ErrBufferFull = errors.New("buffer full")
+
+	// ErrInvalidUTF8 is returned by a Reader that encounters invalid UTF-8
+	// when using the ErrorInvalid policy.
+	ErrInvalidUTF8 = errors.New("invalid UTF-8")
+)
+
+// DefaultReaderSize is the buffer size of a Reader, in runes, if no size is
+// given.
+const DefaultReaderSize = 4096
+
+// minReaderSize is the smallest buffer size of a Reader.
+const minReaderSize = 16
+
+// maxConsecutiveEmptyReads is the number of reads returning no data and no
+// error after which a Reader gives up with io.ErrNoProgress.
+const maxConsecutiveEmptyReads = 100
+
+// InvalidPolicy determines how a Reader handles invalid UTF-8.
+type InvalidPolicy int
+
+const (
+	// ReplaceInvalid replaces each invalid byte with the replacement rune. For
+	// UTF-16, each invalid code unit is replaced.
+	ReplaceInvalid InvalidPolicy = iota
+
+	// SkipInvalid drops invalid bytes.
+	SkipInvalid
+
+	// ErrorInvalid stops reading at the first invalid byte. The reader
+	// returns a *LexError at the position of the invalid input wrapping
+	// ErrInvalidUTF8 or ErrInvalidUTF16 once the runes preceding it have
+	// been read.
+	ErrorInvalid
+)
+
+// ReaderOptions configures a Reader.
+type ReaderOptions struct {
+	// Size is the buffer size in runes. It is the largest number of runes
+	// that can be peeked at once. If zero, DefaultReaderSize is used.
+	Size int
+
+	// Encoding is the encoding of the input. A byte order mark for the
+	// encoding at the start of the input is skipped. If zero, the encoding is
+	// detected from the byte order mark (see DetectEncoding).
+	Encoding Encoding
+
+	// ByteOffsets enables ByteOffset. The Reader then records the position
+	// of each rune whose encoded size differs from the usual size for the
+	// encoding, such as each multibyte rune in UTF-8, using memory that grows
+	// with the input.
+	ByteOffsets bool
+
+	// Invalid is the policy for handling invalid input.
+	Invalid InvalidPolicy
+
+	// Replacement is the rune used in place of invalid bytes by the
+	// ReplaceInvalid policy. If zero, utf8.RuneError (U+FFFD) is used.
+	Replacement rune
+}
+
+// Reader is a BufferedRuneReader that decodes text from an io.Reader. The
+// encoding is detected from the byte order mark, defaulting to UTF-8, unless
+// an encoding is given in ReaderOptions.
+type Reader struct {
+	// r is the underlying reader.
+	r io.Reader
+
+	// opts are the reader's options.
+	opts ReaderOptions
+
+	// runes holds the decoded runes runes[start:end].
+	runes      []rune
+	start, end int
+
+	// sizes holds the encoded size in bytes of each rune in runes.
+	sizes []int
+
+	// raw holds the bytes raw[rawStart:rawEnd] read from r but not yet
+	// decoded.
+	raw              []byte
+	rawStart, rawEnd int
+
+	// offset is the byte offset in the input of raw[rawStart].
+	offset int
+
+	// enc is the encoding of the input. It is DetectEncoding until the
+	// byte order mark has been checked.
+	enc Encoding
+
+	// sniffed indicates that the start of the input has been checked for a
+	// byte order mark.
+	sniffed bool
+
+	// bomLen is the length of the byte order mark skipped.
+	bomLen int
+
+	// pos, line and column are the position of the next rune decoded.
+	pos, line, column int
+
+	// deltas maps rune positions to byte offsets if ByteOffsets is set. See
+	// ByteOffset.
+	deltas []offsetDelta
+
+	// err is the error returned by r or encountered decoding the input.
+	err error
+}
+
+// offsetDelta records that from the rune position pos the byte offset of each
+// rune differs by delta from the offset it would have if every rune had the
+// encoding's usual width.
+type offsetDelta struct {
+	pos, delta int
+}
+
+// NewReader returns a new Reader that reads from r.
+func NewReader(r io.Reader, opts ReaderOptions) *Reader {
+	if opts.Size <= 0 {
+		opts.Size = DefaultReaderSize
+	}
+	opts.Size = max(opts.Size, minReaderSize)
+	if opts.Replacement == 0 {
+		opts.Replacement = utf8.RuneError
+	}
+	return &Reader{
+		r:     r,
+		opts:  opts,
+		runes: make([]rune, opts.Size),
+		sizes: make([]int, opts.Size),
+		raw:   make([]byte, opts.Size),
+		enc:   opts.Encoding,
+	}
+}
+
+// fill reads from the underlying reader once and decodes the input into the
+// rune buffer.
+func (r *Reader) fill() {
+	// Move the buffered runes to the start of the buffer.
+	if r.start > 0 {
+		copy(r.runes, r.runes[r.start:r.end])
+		copy(r.sizes, r.sizes[r.start:r.end])
+		r.end -= r.start
+		r.start = 0
+	}
+
+	r.decode()
+	if r.end == len(r.runes) || r.err != nil {
+		return
+	}
+
+	// Move the undecoded bytes to the start of the buffer and read more.
+	copy(r.raw, r.raw[r.rawStart:r.rawEnd])
+	r.rawEnd -= r.rawStart
+	r.rawStart = 0
+	for i := 0; i < maxConsecutiveEmptyReads; i++ {
+		n, err := r.r.Read(r.raw[r.rawEnd:])
+		r.rawEnd += n
+		if err != nil {
+			r.err = err
+		}
+		if n > 0 || err != nil {
+			r.decode()
+			return
+		}
+	}
+	r.err = io.ErrNoProgress
+}
+
+// decode decodes the buffered bytes into the rune buffer.
+func (r *Reader) decode() {
+	if !r.sniff() {
+		return
+	}
+	for r.end < len(r.runes) && r.rawStart < r.rawEnd {
+		rn, size, ok := r.enc.decode(r.raw[r.rawStart:r.rawEnd], r.err != nil)
+		if size == 0 {
+			// NOTE: Wait for the rest of the rune.
+			return
+		}
+		if !ok {
+			switch r.opts.Invalid {
+			case SkipInvalid:
+				r.rawStart += size
+				r.offset += size
+				r.addDelta(r.pos, size)
+				continue
+			case ErrorInvalid:
+				r.err = &LexError{
+					Pos:    r.pos,
+					Line:   r.line,
+					Column: r.column,
+					Err:    fmt.Errorf("%w: %s at byte offset %d", r.enc.invalid(), r.enc, r.offset),
+				}
+				r.rawStart = r.rawEnd
+				return
+			default:
+				rn = r.opts.Replacement
+			}
+		}
+		r.runes[r.end] = rn
+		r.sizes[r.end] = size
+		r.end++
+		r.rawStart += size
+		r.offset += size
+
+		if size != r.enc.width() {
+			r.addDelta(r.pos+1, size-r.enc.width())
+		}
+		r.pos++
+		r.column++
+		if rn == '\n' {
+			r.line++
+			r.column = 0
+		}
+	}
+}
+
+// sniff detects the encoding and skips the byte order mark at the start of the
+// input. It returns false if more input is needed.
+func (r *Reader) sniff() bool {
+	if r.sniffed {
+		return true
+	}
+	b := r.raw[r.rawStart:r.rawEnd]
+	if len(b) < maxBOMLen && r.err == nil {
+		return false
+	}
+	r.enc, r.bomLen = r.enc.sniff(b)
+	r.rawStart += r.bomLen
+	r.offset += r.bomLen
+	r.sniffed = true
+	return true
+}
+
+// addDelta adds n to the byte offset delta of the runes from pos. It is a
+// no-op unless ByteOffsets is set.
+func (r *Reader) addDelta(pos, n int) {
+	if !r.opts.ByteOffsets {
+		return
+	}
+	var delta int
+	if len(r.deltas) > 0 {
+		last := &r.deltas[len(r.deltas)-1]
+		if last.pos == pos {
+			last.delta += n
+			return
+		}
+		delta = last.delta
+	}
+	r.deltas = append(r.deltas, offsetDelta{pos: pos, delta: delta + n})
+}
+
+// Encoding returns the encoding of the input. If the encoding is detected it
+// returns DetectEncoding until the start of the input has been read.
+func (r *Reader) Encoding() Encoding {
+	return r.enc
+}
+
+// ByteOffset returns the byte offset in the original input of the rune at
+// position pos, such as a Lexeme's Pos. The offset includes the byte order
+// mark, if any. It returns -1 unless ReaderOptions.ByteOffsets was set.
+func (r *Reader) ByteOffset(pos int) int {
+	if !r.opts.ByteOffsets {
+		return -1
+	}
+	i := sort.Search(len(r.deltas), func(i int) bool {
+		return r.deltas[i].pos > pos
+	})
+	var delta int
+	if i > 0 {
+		delta = r.deltas[i-1].delta
+	}
+	return r.bomLen + pos*r.enc.width() + delta
+}
+
+// more reports whether more runes can be decoded.
+func (r *Reader) more() bool {
+	return r.err == nil || r.rawStart < r.rawEnd
+}
+
+// ReadRune implements io.RuneReader.ReadRune. The size returned is the number
+// of bytes of input read.
+func (r *Reader) ReadRune() (rune, int, error) {
+	for r.start == r.end && r.more() {
+		r.fill()
+	}
+	if r.start == r.end {
+		return 0, 0, r.err
+	}
+	rn, size := r.runes[r.start], r.sizes[r.start]
+	r.start++
+	return rn, size, nil
+}
+
+// Buffered implements BufferedRuneReader.Buffered.
+func (r *Reader) Buffered() int {
+	return r.end - r.start
+}
+
+// Peek implements BufferedRuneReader.Peek. If n is larger than the buffer
+// size, Peek returns the full buffer and ErrBufferFull.
+func (r *Reader) Peek(n int) ([]rune, error) {
+	for r.end-r.start < n && r.end-r.start < len(r.runes) && r.more() {
+		r.fill()
+	}
+	if r.end-r.start >= n {
+		return r.runes[r.start : r.start+n], nil
+	}
+	if n > len(r.runes) && r.end-r.start == len(r.runes) {
+		return r.runes[r.start:r.end], ErrBufferFull
+	}
+	return r.runes[r.start:r.end], r.err
+}
+
+// Discard implements BufferedRuneReader.Discard.
+func (r *Reader) Discard(n int) (int, error) {
+	var d int
+	for d < n {
+		if r.start == r.end {
+			if !r.more() {
+				return d, r.err
+			}
+			r.fill()
+			continue
+		}
+		skip := min(n-d, r.end-r.start)
+		r.start += skip
+		d += skip
+	}
+	return d, nil
+}
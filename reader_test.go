@@ -0,0 +1,172 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// readAll reads all runes from r.
+func readAll(r io.RuneReader) (string, error) {
+	var b strings.Builder
+	for {
+		rn, _, err := r.ReadRune()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return b.String(), err
+		}
+		b.WriteRune(rn)
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input string
+		opts  ReaderOptions
+		want  string
+		err   error
+	}{
+		"ascii": {
+			input: "Hello World!",
+			want:  "Hello World!",
+		},
+		"multibyte": {
+			input: strings.Repeat("日本語", 20),
+			want:  strings.Repeat("日本語", 20),
+		},
+		"replace": {
+			input: "a\xffb\xe6\x97",
+			want:  "a�b��",
+		},
+		"replacement": {
+			input: "a\xffb",
+			opts:  ReaderOptions{Replacement: '?'},
+			want:  "a?b",
+		},
+		"skip": {
+			input: "a\xffb\xe6\x97",
+			opts:  ReaderOptions{Invalid: SkipInvalid},
+			want:  "ab",
+		},
+		"error": {
+			input: "ab\xffc",
+			opts:  ReaderOptions{Invalid: ErrorInvalid},
+			want:  "ab",
+			err:   ErrInvalidUTF8,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// NOTE: Read one byte at a time to split runes across reads.
+			r := NewReader(iotest.OneByteReader(strings.NewReader(tc.input)), tc.opts)
+			got, err := readAll(r)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected runes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReader_Peek(t *testing.T) {
+	t.Parallel()
+
+	input := strings.Repeat("é", 40)
+	r := NewReader(strings.NewReader(input), ReaderOptions{Size: 16})
+
+	rns, err := r.Peek(10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(rns), strings.Repeat("é", 10); got != want {
+		t.Errorf("Peek: want: %q, got: %q", want, got)
+	}
+
+	rns, err = r.Peek(17)
+	if !errors.Is(err, ErrBufferFull) {
+		t.Errorf("Peek: want: %v, got: %v", ErrBufferFull, err)
+	}
+	if got, want := len(rns), 16; got != want {
+		t.Errorf("Peek: want: %d runes, got: %d", want, got)
+	}
+
+	_, size, err := r.ReadRune()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := size, utf8.RuneLen('é'); got != want {
+		t.Errorf("ReadRune: want size: %d, got: %d", want, got)
+	}
+
+	d, err := r.Discard(30)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := d, 30; got != want {
+		t.Errorf("Discard: want: %d, got: %d", want, got)
+	}
+
+	rns, err = r.Peek(16)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Peek: want: %v, got: %v", io.EOF, err)
+	}
+	if got, want := len(rns), 9; got != want {
+		t.Errorf("Peek: want: %d runes, got: %d", want, got)
+	}
+
+	d, err = r.Discard(10)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Discard: want: %v, got: %v", io.EOF, err)
+	}
+	if got, want := d, 9; got != want {
+		t.Errorf("Discard: want: %d, got: %d", want, got)
+	}
+}
+
+func TestLexParseReader(t *testing.T) {
+	t.Parallel()
+
+	input := "Hello World!\n"
+	want, err := LexParseString(context.Background(), input, &wordState{}, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := LexParseReader(context.Background(), strings.NewReader(input), &wordState{}, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+}
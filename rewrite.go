@@ -0,0 +1,292 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMaxIterations indicates that rewriting did not reach a fixpoint
+	// within the maximum number of iterations.
+	ErrMaxIterations = errors.New("maximum iterations reached")
+
+	// ErrInvalidRewrite indicates that a rewrite rule produced an invalid
+	// replacement.
+	ErrInvalidRewrite = errors.New("invalid rewrite")
+)
+
+// DefaultMaxIterations is the maximum number of iterations used by Rewrite if
+// RewriteOptions.MaxIterations is not set.
+const DefaultMaxIterations = 100
+
+// Pattern is a structural pattern that matches a node and its children.
+type Pattern[V comparable] struct {
+	// Match reports whether the node's value matches. A nil Match is a
+	// wildcard that matches any value.
+	Match func(V) bool
+
+	// Bind is the name under which the matched node is recorded in the
+	// Bindings. It is ignored if empty.
+	Bind string
+
+	// Children are the patterns for the node's children. If Children is nil
+	// the node's children are not checked. Otherwise, the node must have
+	// exactly len(Children) children, each matching its pattern, unless
+	// Rest is set.
+	Children []*Pattern[V]
+
+	// Rest allows the node to have more children than Children. The
+	// remaining children are recorded under this name in Bindings.Lists.
+	Rest string
+}
+
+// Is returns a function that matches values equal to v for use in a Pattern.
+func Is[V comparable](v V) func(V) bool {
+	return func(x V) bool {
+		return x == v
+	}
+}
+
+// Bindings holds the nodes bound while matching a Pattern.
+type Bindings[V comparable] struct {
+	// Nodes maps names to the nodes bound by Pattern.Bind.
+	Nodes map[string]*Node[V]
+
+	// Lists maps names to the nodes bound by Pattern.Rest.
+	Lists map[string][]*Node[V]
+}
+
+// MatchPattern reports whether n matches p and returns the bindings.
+func MatchPattern[V comparable](p *Pattern[V], n *Node[V]) (Bindings[V], bool) {
+	b := Bindings[V]{
+		Nodes: map[string]*Node[V]{},
+		Lists: map[string][]*Node[V]{},
+	}
+	return b, b.match(p, n)
+}
+
+func (b Bindings[V]) match(p *Pattern[V], n *Node[V]) bool {
+	if n == nil {
+		return false
+	}
+	if p.Match != nil && !p.Match(n.Value) {
+		return false
+	}
+	if p.Children != nil {
+		if len(n.Children) < len(p.Children) {
+			return false
+		}
+		if len(n.Children) > len(p.Children) && p.Rest == "" {
+			return false
+		}
+		for i, cp := range p.Children {
+			if !b.match(cp, n.Children[i]) {
+				return false
+			}
+		}
+		if p.Rest != "" {
+			b.Lists[p.Rest] = n.Children[len(p.Children):]
+		}
+	}
+	if p.Bind != "" {
+		b.Nodes[p.Bind] = n
+	}
+	return true
+}
+
+// Rule is a rewrite rule.
+type Rule[V comparable] struct {
+	// Name is the name of the rule reported in RewriteResult.
+	Name string
+
+	// Pattern is the pattern that a node must match for the rule to apply.
+	Pattern *Pattern[V]
+
+	// Rewrite returns the replacement for the matched node n. It may reuse n
+	// or any bound nodes, each at most once. Returning nil leaves n
+	// unchanged and the rule is not considered to have fired. If the
+	// replacement is a new node without a position, it is given the
+	// position of n.
+	Rewrite func(n *Node[V], b Bindings[V]) *Node[V]
+}
+
+// RewriteOrder is the order in which Rewrite visits nodes.
+type RewriteOrder int
+
+const (
+	// BottomUp visits children before their parents.
+	BottomUp RewriteOrder = iota
+
+	// TopDown visits parents before their children.
+	TopDown
+)
+
+// RewriteOptions configures Rewrite.
+type RewriteOptions struct {
+	// Order is the order in which nodes are visited in each iteration.
+	Order RewriteOrder
+
+	// MaxIterations is the maximum number of iterations over the tree. If
+	// zero, DefaultMaxIterations is used.
+	MaxIterations int
+}
+
+// RewriteResult reports the result of Rewrite.
+type RewriteResult struct {
+	// Iterations is the number of iterations performed.
+	Iterations int
+
+	// Fired maps rule names to the number of times each rule fired.
+	Fired map[string]int
+}
+
+// Rewrite applies the rules to the tree rooted at root until no rule fires.
+// In each iteration the nodes are visited in the given order and the first
+// matching rule that fires for a node replaces it. Parent links are kept
+// consistent. The possibly new root is returned.
+//
+// If the tree does not reach a fixpoint within the maximum number of
+// iterations, ErrMaxIterations is returned along with the tree as rewritten
+// so far.
+func Rewrite[V comparable](root *Node[V], rules []Rule[V], opts RewriteOptions) (*Node[V], RewriteResult, error) {
+	res := RewriteResult{Fired: map[string]int{}}
+	maxIter := opts.MaxIterations
+	if maxIter <= 0 {
+		maxIter = DefaultMaxIterations
+	}
+
+	for {
+		if res.Iterations >= maxIter {
+			return root, res, fmt.Errorf("%w: %d", ErrMaxIterations, maxIter)
+		}
+		res.Iterations++
+
+		order := PostOrder(root)
+		if opts.Order == TopDown {
+			order = PreOrder(root)
+		}
+		var nodes []*Node[V]
+		for n := range order {
+			nodes = append(nodes, n)
+		}
+
+		var fired bool
+		for _, n := range nodes {
+			if !attached(n, root) {
+				continue
+			}
+			for i := range rules {
+				r := &rules[i]
+				b, ok := MatchPattern(r.Pattern, n)
+				if !ok {
+					continue
+				}
+				repl := r.Rewrite(n, b)
+				if repl == nil {
+					continue
+				}
+				if err := replaceNode(n, repl); err != nil {
+					return root, res, fmt.Errorf("rule %q: %w", r.Name, err)
+				}
+				if n == root {
+					root = repl
+				}
+				res.Fired[r.Name]++
+				fired = true
+				break
+			}
+		}
+
+		if !fired {
+			return root, res, nil
+		}
+	}
+}
+
+// attached reports whether n is in the tree rooted at root. Each node on the
+// path to root must be one of its parent's children.
+func attached[V comparable](n, root *Node[V]) bool {
+	for ; n != nil; n = n.Parent {
+		if n == root {
+			return true
+		}
+		if n.Index() < 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// replaceNode replaces old with repl in old's parent and updates the parent
+// links in repl's subtree. If old is not part of repl it is detached from the
+// tree.
+func replaceNode[V comparable](old, repl *Node[V]) error {
+	parent := old.Parent
+	idx := -1
+	if parent != nil {
+		for i, c := range parent.Children {
+			if c == old {
+				idx = i
+				break
+			}
+		}
+	}
+
+	// Check that the replacement doesn't contain a node more than once or
+	// any ancestor of old before updating the parent links.
+	seen := map[*Node[V]]bool{}
+	for a := parent; a != nil; a = a.Parent {
+		seen[a] = true
+	}
+	var invalid bool
+	Walk[V](repl, VisitorFuncs[V]{
+		EnterFunc: func(n *Node[V]) WalkAction {
+			if seen[n] {
+				invalid = true
+				return WalkStop
+			}
+			seen[n] = true
+			return WalkContinue
+		},
+	})
+	if invalid {
+		return fmt.Errorf("%w: node appears more than once in the tree", ErrInvalidRewrite)
+	}
+
+	// NOTE: old's parent link is restored below if it is part of repl.
+	old.Parent = nil
+	for n := range PreOrder(repl) {
+		for _, c := range n.Children {
+			if c != nil {
+				c.Parent = n
+			}
+		}
+	}
+
+	if repl.Pos == 0 && repl.Line == 0 && repl.Column == 0 && repl != old {
+		repl.Pos = old.Pos
+		repl.Line = old.Line
+		repl.Column = old.Column
+		repl.Source = old.Source
+	}
+
+	repl.Parent = parent
+	if idx >= 0 {
+		parent.Children[idx] = repl
+	}
+	return nil
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func isNum(v string) bool {
+	_, err := strconv.Atoi(v)
+	return err == nil
+}
+
+// foldRule folds the addition of two integer constants.
+var foldRule = Rule[string]{
+	Name: "fold",
+	Pattern: &Pattern[string]{
+		Match: Is("+"),
+		Children: []*Pattern[string]{
+			{Match: isNum, Bind: "x"},
+			{Match: isNum, Bind: "y"},
+		},
+	},
+	Rewrite: func(_ *Node[string], b Bindings[string]) *Node[string] {
+		x, _ := strconv.Atoi(b.Nodes["x"].Value)
+		y, _ := strconv.Atoi(b.Nodes["y"].Value)
+		return &Node[string]{Value: strconv.Itoa(x + y)}
+	},
+}
+
+// negRule desugars neg(x) to -(0, x) reusing x.
+var negRule = Rule[string]{
+	Name: "neg",
+	Pattern: &Pattern[string]{
+		Match:    Is("neg"),
+		Children: []*Pattern[string]{{Bind: "x"}},
+	},
+	Rewrite: func(_ *Node[string], b Bindings[string]) *Node[string] {
+		return &Node[string]{
+			Value: "-",
+			Children: []*Node[string]{
+				{Value: "0"},
+				b.Nodes["x"],
+			},
+		}
+	},
+}
+
+// checkParents checks that the parent links in the tree rooted at n are
+// consistent with the children.
+func checkParents[V comparable](t *testing.T, n *Node[V]) {
+	t.Helper()
+
+	for p := range PreOrder(n) {
+		for _, c := range p.Children {
+			if c.Parent != p {
+				t.Errorf("inconsistent parent link for %v: want: %v, got: %v", c.Value, p.Value, c.Parent)
+			}
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	// (1 + (2 + 3)) + neg(x)
+	root := addParent(&Node[string]{
+		Value: "+",
+		Children: []*Node[string]{
+			{
+				Value: "+",
+				Pos:   0,
+				Children: []*Node[string]{
+					{Value: "1", Pos: 1, Column: 1},
+					{
+						Value:  "+",
+						Pos:    5,
+						Column: 5,
+						Children: []*Node[string]{
+							{Value: "2", Pos: 6, Column: 6},
+							{Value: "3", Pos: 10, Column: 10},
+						},
+					},
+				},
+			},
+			{
+				Value:  "neg",
+				Pos:    16,
+				Column: 16,
+				Children: []*Node[string]{
+					{Value: "x", Pos: 20, Column: 20},
+				},
+			},
+		},
+	})
+
+	got, res, err := Rewrite(root, []Rule[string]{foldRule, negRule}, RewriteOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := addParent(&Node[string]{
+		Value: "+",
+		Children: []*Node[string]{
+			{Value: "6"},
+			{
+				Value:  "-",
+				Pos:    16,
+				Column: 16,
+				Children: []*Node[string]{
+					{Value: "0"},
+					{Value: "x", Pos: 20, Column: 20},
+				},
+			},
+		},
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+	checkParents(t, got)
+
+	wantRes := RewriteResult{
+		Iterations: 2,
+		Fired:      map[string]int{"fold": 2, "neg": 1},
+	}
+	if diff := cmp.Diff(wantRes, res); diff != "" {
+		t.Errorf("unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestRewrite_topDown(t *testing.T) {
+	t.Parallel()
+
+	root := addParent(&Node[string]{
+		Value: "+",
+		Children: []*Node[string]{
+			{Value: "1"},
+			{
+				Value: "+",
+				Children: []*Node[string]{
+					{Value: "2"},
+					{Value: "3"},
+				},
+			},
+		},
+	})
+
+	got, res, err := Rewrite(root, []Rule[string]{foldRule}, RewriteOptions{Order: TopDown})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(&Node[string]{Value: "6"}, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+	// The root can only be folded in the second iteration when visiting
+	// top-down. The third iteration finds no more changes.
+	if got, want := res.Iterations, 3; got != want {
+		t.Errorf("Iterations: want: %d, got: %d", want, got)
+	}
+}
+
+func TestRewrite_dropped(t *testing.T) {
+	t.Parallel()
+
+	root := addParent(&Node[string]{
+		Value: "root",
+		Children: []*Node[string]{
+			{
+				Value: "drop",
+				Children: []*Node[string]{
+					{Value: "x"},
+					{Value: "y"},
+				},
+			},
+		},
+	})
+	drop := root.Children[0]
+	x, y := drop.Children[0], drop.Children[1]
+
+	rules := []Rule[string]{
+		{
+			Name:    "drop",
+			Pattern: &Pattern[string]{Match: Is("drop")},
+			Rewrite: func(n *Node[string], _ Bindings[string]) *Node[string] {
+				// Keep y but drop x.
+				return &Node[string]{Value: "kept", Children: []*Node[string]{n.Children[1]}}
+			},
+		},
+		{
+			Name:    "x",
+			Pattern: &Pattern[string]{Match: Is("x")},
+			Rewrite: func(*Node[string], Bindings[string]) *Node[string] {
+				return &Node[string]{Value: "X"}
+			},
+		},
+	}
+
+	got, res, err := Rewrite(root, rules, RewriteOptions{Order: TopDown})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := addParent(&Node[string]{
+		Value: "root",
+		Children: []*Node[string]{
+			{
+				Value:    "kept",
+				Children: []*Node[string]{{Value: "y"}},
+			},
+		},
+	})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string]int{"drop": 1}, res.Fired); diff != "" {
+		t.Errorf("unexpected fired rules (-want +got):\n%s", diff)
+	}
+	if drop.Parent != nil || x.Parent != drop || y.Parent != got.Children[0] {
+		t.Errorf("unexpected parent links")
+	}
+}
+
+func TestRewrite_maxIterations(t *testing.T) {
+	t.Parallel()
+
+	// wrap wraps every "x" node in a new node forever.
+	wrap := Rule[string]{
+		Name:    "wrap",
+		Pattern: &Pattern[string]{Match: Is("x")},
+		Rewrite: func(n *Node[string], _ Bindings[string]) *Node[string] {
+			return &Node[string]{Value: "wrap", Children: []*Node[string]{n}}
+		},
+	}
+
+	got, res, err := Rewrite(&Node[string]{Value: "x"}, []Rule[string]{wrap}, RewriteOptions{MaxIterations: 3})
+	if !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := res.Fired["wrap"], 3; got != want {
+		t.Errorf("Fired: want: %d, got: %d", want, got)
+	}
+	checkParents(t, got)
+}
+
+func TestRewrite_invalid(t *testing.T) {
+	t.Parallel()
+
+	dup := Rule[string]{
+		Name:    "dup",
+		Pattern: &Pattern[string]{Match: Is("x"), Bind: "x"},
+		Rewrite: func(n *Node[string], _ Bindings[string]) *Node[string] {
+			return &Node[string]{Children: []*Node[string]{n, n}}
+		},
+	}
+
+	_, _, err := Rewrite(newTree(&Node[string]{Value: "x"}), []Rule[string]{dup}, RewriteOptions{})
+	if !errors.Is(err, ErrInvalidRewrite) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMatchPattern_rest(t *testing.T) {
+	t.Parallel()
+
+	p := &Pattern[string]{
+		Match:    Is("call"),
+		Children: []*Pattern[string]{{Bind: "fn"}},
+		Rest:     "args",
+	}
+	n := addParent(&Node[string]{
+		Value: "call",
+		Children: []*Node[string]{
+			{Value: "f"},
+			{Value: "a"},
+			{Value: "b"},
+		},
+	})
+
+	b, ok := MatchPattern(p, n)
+	if !ok {
+		t.Fatalf("pattern did not match")
+	}
+	if got, want := b.Nodes["fn"], n.Children[0]; got != want {
+		t.Errorf("fn: want: %v, got: %v", want.Value, got.Value)
+	}
+	if diff := cmp.Diff(n.Children[1:], b.Lists["args"]); diff != "" {
+		t.Errorf("args (-want +got):\n%s", diff)
+	}
+
+	p.Rest = ""
+	if _, ok := MatchPattern(p, n); ok {
+		t.Errorf("pattern without Rest matched extra children")
+	}
+}
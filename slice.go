@@ -0,0 +1,118 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// SliceSource is a BufferedRuneReader over input held in memory. When the
+// Lexer reads from a SliceSource, lexeme values and trivia are slices of the
+// input rather than copies.
+type SliceSource interface {
+	BufferedRuneReader
+
+	// Offset returns the byte offset of the next rune in the input.
+	Offset() int
+
+	// Slice returns the input between the byte offsets start and end.
+	Slice(start, end int) string
+}
+
+// SliceReader is a SliceSource that reads from a string or byte slice.
+type SliceReader struct {
+	// s is the input.
+	s string
+
+	// off is the byte offset of the next rune.
+	off int
+
+	// buf holds the runes returned by the last call to Peek.
+	buf []rune
+}
+
+// NewStringReader returns a SliceReader that reads from s.
+func NewStringReader(s string) *SliceReader {
+	return &SliceReader{s: s}
+}
+
+// NewBytesReader returns a SliceReader that reads from b. b is copied once so
+// that the lexeme values produced by a Lexer reading from the SliceReader are
+// slices of the copy and b may be modified afterwards.
+func NewBytesReader(b []byte) *SliceReader {
+	return &SliceReader{s: string(b)}
+}
+
+// ReadRune implements io.RuneReader.ReadRune. Invalid UTF-8 is returned as
+// utf8.RuneError with a size of one byte.
+func (r *SliceReader) ReadRune() (rune, int, error) {
+	r.buf = r.buf[:0]
+	if r.off >= len(r.s) {
+		return 0, 0, io.EOF
+	}
+	rn, size := utf8.DecodeRuneInString(r.s[r.off:])
+	r.off += size
+	return rn, size, nil
+}
+
+// Buffered returns the number of runes returned by the last call to Peek.
+// All of the input is held in memory so the reader never needs to fill a
+// buffer.
+func (r *SliceReader) Buffered() int {
+	return len(r.buf)
+}
+
+// Peek implements BufferedRuneReader.Peek. The returned runes are decoded
+// into a buffer that is reused by the next call to Peek. Because all of the
+// input is in memory Peek never returns ErrBufferFull.
+func (r *SliceReader) Peek(n int) ([]rune, error) {
+	r.buf = r.buf[:0]
+	off := r.off
+	for len(r.buf) < n && off < len(r.s) {
+		rn, size := utf8.DecodeRuneInString(r.s[off:])
+		r.buf = append(r.buf, rn)
+		off += size
+	}
+	if len(r.buf) < n {
+		return r.buf, io.EOF
+	}
+	return r.buf, nil
+}
+
+// Discard implements BufferedRuneReader.Discard.
+func (r *SliceReader) Discard(n int) (int, error) {
+	r.buf = r.buf[:0]
+	var d int
+	for d < n && r.off < len(r.s) {
+		_, size := utf8.DecodeRuneInString(r.s[r.off:])
+		r.off += size
+		d++
+	}
+	if d < n {
+		return d, io.EOF
+	}
+	return d, nil
+}
+
+// Offset implements SliceSource.Offset.
+func (r *SliceReader) Offset() int {
+	return r.off
+}
+
+// Slice implements SliceSource.Slice.
+func (r *SliceReader) Slice(start, end int) string {
+	return r.s[start:end]
+}
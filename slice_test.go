@@ -0,0 +1,142 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+func TestSliceReader(t *testing.T) {
+	t.Parallel()
+
+	r := NewBytesReader([]byte("aé\xffbc"))
+
+	rns, err := r.Peek(3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]rune{'a', 'é', utf8.RuneError}, rns); diff != "" {
+		t.Errorf("Peek (-want +got):\n%s", diff)
+	}
+	if got, want := r.Buffered(), 3; got != want {
+		t.Errorf("Buffered: want: %d, got: %d", want, got)
+	}
+
+	rn, size, err := r.ReadRune()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rn != 'a' || size != 1 {
+		t.Errorf("ReadRune: want: 'a', 1, got: %q, %d", rn, size)
+	}
+
+	d, err := r.Discard(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := d, 2; got != want {
+		t.Errorf("Discard: want: %d, got: %d", want, got)
+	}
+	if got, want := r.Offset(), 4; got != want {
+		t.Errorf("Offset: want: %d, got: %d", want, got)
+	}
+	if got, want := r.Slice(1, r.Offset()), "é\xff"; got != want {
+		t.Errorf("Slice: want: %q, got: %q", want, got)
+	}
+
+	rns, err = r.Peek(3)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Peek: want: %v, got: %v", io.EOF, err)
+	}
+	if got, want := string(rns), "bc"; got != want {
+		t.Errorf("Peek: want: %q, got: %q", want, got)
+	}
+
+	d, err = r.Discard(3)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Discard: want: %v, got: %v", io.EOF, err)
+	}
+	if got, want := d, 2; got != want {
+		t.Errorf("Discard: want: %d, got: %d", want, got)
+	}
+	if _, _, err := r.ReadRune(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRune: want: %v, got: %v", io.EOF, err)
+	}
+}
+
+func TestLexer_sliceSource(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input  string
+		state  func() State
+		trivia bool
+	}{
+		"words": {
+			input: "Hello World!\nfoo",
+			state: func() State { return &wordState{} },
+		},
+		"multibyte": {
+			input: "héllo wörld\n日本",
+			state: func() State { return &wordState{} },
+		},
+		"trivia": {
+			input:  "  a b // c\n\n/* d */ e  \n",
+			state:  func() State { return SkipComments(CComments, &spaceWordState{}) },
+			trivia: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lex := func(r BufferedRuneReader) []*Lexeme {
+				l := NewLexer(r, tc.state(), WithTrivia(tc.trivia))
+				var lexemes []*Lexeme
+				for lexeme := range l.Lex(context.Background()) {
+					lexemes = append(lexemes, lexeme)
+				}
+				if err := l.Err(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return lexemes
+			}
+
+			want := lex(runeio.NewReader(strings.NewReader(tc.input)))
+			got := lex(NewStringReader(tc.input))
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+			}
+
+			// Values are found in the input at their byte offset.
+			for _, lexeme := range got {
+				end := lexeme.Offset + len(lexeme.Value)
+				if end > len(tc.input) || tc.input[lexeme.Offset:end] != lexeme.Value {
+					t.Errorf("value %q not found in input at offset %d", lexeme.Value, lexeme.Offset)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pos is a compact position in the sources of a FileSet. It identifies a
+// source and a rune offset in it and can be resolved to a Position with
+// FileSet.Position. The zero value is NoPos.
+type Pos int64
+
+// NoPos is the zero Pos. It doesn't refer to any source.
+const NoPos Pos = 0
+
+// posOffsetBits is the number of bits of a Pos used for the offset.
+const posOffsetBits = 32
+
+// IsValid reports whether p refers to a source.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// Position is a resolved position in a source.
+type Position struct {
+	// Filename is the name of the source. It is empty if the position isn't
+	// in a named source.
+	Filename string
+
+	// Offset is the position in runes in the source.
+	Offset int
+
+	// Line is the line number in the source (zero indexed).
+	Line int
+
+	// Column is the column in the line (zero indexed).
+	Column int
+}
+
+// String returns the position as filename:line:column with the line and
+// column one-indexed. The filename is omitted if it is empty.
+func (p Position) String() string {
+	return positionString(p.Filename, p.Line, p.Column)
+}
+
+// positionString formats a position for error messages.
+func positionString(filename string, line, column int) string {
+	if filename == "" {
+		return fmt.Sprintf("%d:%d", line+1, column+1)
+	}
+	return fmt.Sprintf("%s:%d:%d", filename, line+1, column+1)
+}
+
+// Source is a named input, such as a file, registered with a FileSet. A Lexer
+// given a Source with WithSource or Lexer.SetSource records the start of each
+// line in it and sets the Source of the lexemes it emits.
+type Source struct {
+	// name is the name of the source.
+	name string
+
+	// index is the index of the source in its FileSet plus one.
+	index int
+
+	// mu protects lines.
+	mu sync.Mutex
+
+	// lines holds the offset of the start of each line after the first.
+	lines []int
+}
+
+// Name returns the name of the source. It returns an empty string if s is
+// nil.
+func (s *Source) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+// AddLine records that a line starts at the rune offset. Offsets must be
+// added in increasing order and offsets not greater than the last one added
+// are ignored.
+func (s *Source) AddLine(offset int) {
+	s.mu.Lock()
+	if n := len(s.lines); offset > 0 && (n == 0 || offset > s.lines[n-1]) {
+		s.lines = append(s.lines, offset)
+	}
+	s.mu.Unlock()
+}
+
+// Pos returns the Pos of the rune offset in the source. NoPos is returned if
+// s is nil or wasn't added to a FileSet, or if offset is negative or doesn't
+// fit in a Pos.
+func (s *Source) Pos(offset int) Pos {
+	if s == nil || s.index == 0 || offset < 0 || int64(offset) >= 1<<posOffsetBits {
+		return NoPos
+	}
+	return Pos(int64(s.index)<<posOffsetBits | int64(offset))
+}
+
+// Position returns the Position of the rune offset in the source. The line
+// and column are only known for offsets up to the last line recorded with
+// AddLine. If s is nil, the offset is treated as being on the first line.
+func (s *Source) Position(offset int) Position {
+	if s == nil {
+		return Position{Offset: offset, Column: offset}
+	}
+	s.mu.Lock()
+	line := sort.Search(len(s.lines), func(i int) bool {
+		return s.lines[i] > offset
+	})
+	start := 0
+	if line > 0 {
+		start = s.lines[line-1]
+	}
+	s.mu.Unlock()
+
+	return Position{
+		Filename: s.name,
+		Offset:   offset,
+		Line:     line,
+		Column:   offset - start,
+	}
+}
+
+// FileSet is a set of sources. It is safe for concurrent use so that multiple
+// inputs can be lexed and parsed at the same time.
+type FileSet struct {
+	mu      sync.RWMutex
+	sources []*Source
+}
+
+// NewFileSet returns a new empty FileSet.
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddSource adds a new source with the given name to the set and returns it.
+func (f *FileSet) AddSource(name string) *Source {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := &Source{
+		name:  name,
+		index: len(f.sources) + 1,
+	}
+	f.sources = append(f.sources, s)
+	return s
+}
+
+// Source returns the source containing p or nil if p isn't in the set.
+func (f *FileSet) Source(p Pos) *Source {
+	i := int(p>>posOffsetBits) - 1
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if i < 0 || i >= len(f.sources) {
+		return nil
+	}
+	return f.sources[i]
+}
+
+// Position returns the Position of p. The zero Position is returned if p
+// isn't in the set.
+func (f *FileSet) Position(p Pos) Position {
+	s := f.Source(p)
+	if s == nil {
+		return Position{}
+	}
+	return s.Position(int(p & (1<<posOffsetBits - 1)))
+}
+
+// FilePos returns the compact Pos of the lexeme in the FileSet of its Source.
+// NoPos is returned if the lexeme has no Source.
+func (l *Lexeme) FilePos() Pos {
+	return l.Source.Pos(l.Pos)
+}
+
+// Position returns the position of the lexeme, including the name of its
+// Source if it has one.
+func (l *Lexeme) Position() Position {
+	return Position{
+		Filename: l.Source.Name(),
+		Offset:   l.Pos,
+		Line:     l.Line,
+		Column:   l.Column,
+	}
+}
+
+// FilePos returns the compact Pos of the node in the FileSet of its Source.
+// NoPos is returned if the node has no Source.
+func (n *Node[V]) FilePos() Pos {
+	return n.Source.Pos(n.Pos)
+}
+
+// Position returns the position of the node, including the name of its
+// Source if it has one.
+func (n *Node[V]) Position() Position {
+	return Position{
+		Filename: n.Source.Name(),
+		Offset:   n.Pos,
+		Line:     n.Line,
+		Column:   n.Column,
+	}
+}
+
+// setFilename sets the Filename of the positioned errors in err's chain that
+// don't have one.
+func setFilename(err error, filename string) {
+	if filename == "" {
+		return
+	}
+	var lexErr *LexError
+	if errors.As(err, &lexErr) && lexErr.Filename == "" {
+		lexErr.Filename = filename
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) && limitErr.Filename == "" {
+		limitErr.Filename = filename
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) && panicErr.Filename == "" {
+		panicErr.Filename = filename
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// parseWordAt is like parseWord but creates each node at the position of its
+// lexeme.
+func parseWordAt(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	l := p.Peek()
+	if l == nil {
+		return nil, nil
+	}
+	p.Node(l.Value)
+	_ = p.Next()
+	return parseWordAt, nil
+}
+
+func TestFileSet(t *testing.T) {
+	t.Parallel()
+
+	fset := NewFileSet()
+	a := fset.AddSource("a.txt")
+	b := fset.AddSource("b.txt")
+	b.AddLine(4)
+	b.AddLine(10)
+	b.AddLine(10)
+
+	testCases := map[string]struct {
+		pos  Pos
+		want Position
+		str  string
+	}{
+		"start": {
+			pos:  a.Pos(0),
+			want: Position{Filename: "a.txt"},
+			str:  "a.txt:1:1",
+		},
+		"first line": {
+			pos:  b.Pos(3),
+			want: Position{Filename: "b.txt", Offset: 3, Column: 3},
+			str:  "b.txt:1:4",
+		},
+		"line start": {
+			pos:  b.Pos(4),
+			want: Position{Filename: "b.txt", Offset: 4, Line: 1},
+			str:  "b.txt:2:1",
+		},
+		"last line": {
+			pos:  b.Pos(12),
+			want: Position{Filename: "b.txt", Offset: 12, Line: 2, Column: 2},
+			str:  "b.txt:3:3",
+		},
+		"no pos": {
+			pos:  NoPos,
+			want: Position{},
+			str:  "1:1",
+		},
+		"negative offset": {
+			pos:  b.Pos(-1),
+			want: Position{},
+			str:  "1:1",
+		},
+		"offset too large": {
+			pos:  b.Pos(1 << posOffsetBits),
+			want: Position{},
+			str:  "1:1",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := fset.Position(tc.pos)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected position (-want +got):\n%s", diff)
+			}
+			if got, want := got.String(), tc.str; got != want {
+				t.Errorf("String: want: %q, got: %q", want, got)
+			}
+		})
+	}
+
+	if !a.Pos(0).IsValid() || NoPos.IsValid() {
+		t.Errorf("IsValid: unexpected result")
+	}
+	if got := fset.Source(b.Pos(1)); got != b {
+		t.Errorf("Source: want: %v, got: %v", b.Name(), got.Name())
+	}
+
+	var nilSource *Source
+	if diff := cmp.Diff(Position{Offset: 3, Column: 3}, nilSource.Position(3)); diff != "" {
+		t.Errorf("nil Position (-want +got):\n%s", diff)
+	}
+
+	lexeme := &Lexeme{Pos: 3, Source: b}
+	if got, want := lexeme.FilePos(), b.Pos(3); got != want {
+		t.Errorf("FilePos: want: %v, got: %v", want, got)
+	}
+	for _, l := range []*Lexeme{{Pos: 3}, {Pos: 3, Source: &Source{name: "c.txt"}}} {
+		if got := l.FilePos(); got != NoPos {
+			t.Errorf("FilePos: want: %v, got: %v", NoPos, got)
+		}
+	}
+}
+
+func TestLexParse_source(t *testing.T) {
+	t.Parallel()
+
+	fset := NewFileSet()
+	inputs := map[string]string{
+		"a.txt": "Hello\nWorld!",
+		"b.txt": "foo\nbar\nbaz",
+	}
+
+	// Parse each input concurrently into the same FileSet.
+	var wg sync.WaitGroup
+	roots := map[string]*Node[string]{}
+	var mu sync.Mutex
+	for name, input := range inputs {
+		src := fset.AddSource(name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			root, err := LexParseString(context.Background(), input, &wordState{}, parseWordAt, WithSource(src))
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			mu.Lock()
+			roots[name] = root
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for name, root := range roots {
+		var got []string
+		for _, n := range root.Children {
+			if n.Source.Name() != name {
+				t.Errorf("%s: Source: want: %q, got: %q", n.Value, name, n.Source.Name())
+			}
+			pos := fset.Position(n.FilePos())
+			if diff := cmp.Diff(n.Position(), pos); diff != "" {
+				t.Errorf("%s: unexpected position (-want +got):\n%s", n.Value, diff)
+			}
+			got = append(got, pos.String())
+		}
+		want := map[string][]string{
+			"a.txt": {"a.txt:1:1", "a.txt:2:1"},
+			"b.txt": {"b.txt:1:1", "b.txt:2:1", "b.txt:3:1"},
+		}[name]
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: unexpected positions (-want +got):\n%s", name, diff)
+		}
+	}
+}
+
+func TestLexParse_sourceErrors(t *testing.T) {
+	t.Parallel()
+
+	fset := NewFileSet()
+
+	t.Run("lexer", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LexParseString(context.Background(), "a\nb c", &wordState{}, parseWordAt,
+			WithSource(fset.AddSource("lex.txt")),
+			WithLimits(Limits{MaxLexemes: 2}),
+		)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("unexpected error: want: %v, got: %v", ErrLimitExceeded, err)
+		}
+		if got, want := err.Error(), "lex.txt:2:3: "; !strings.HasPrefix(got, want) {
+			t.Errorf("Error: want prefix: %q, got: %q", want, got)
+		}
+	})
+
+	t.Run("parser", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LexParseString(context.Background(), "a\nb c", &wordState{}, parseWordAt,
+			WithSource(fset.AddSource("parse.txt")),
+			WithLimits(Limits{MaxNodes: 1}),
+		)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("unexpected error: want: %v, got: %v", ErrLimitExceeded, err)
+		}
+		if got, want := err.Error(), "parse.txt:2:1: "; !strings.HasPrefix(got, want) {
+			t.Errorf("Error: want prefix: %q, got: %q", want, got)
+		}
+	})
+}
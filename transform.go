@@ -0,0 +1,225 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LexemeSource is a source of lexemes read by a Parser.
+type LexemeSource interface {
+	// Next returns the next lexeme or nil if there are no more lexemes.
+	Next() *Lexeme
+}
+
+// LexemeSourceFunc is a function that implements LexemeSource.
+type LexemeSourceFunc func() *Lexeme
+
+// Next implements LexemeSource.
+func (f LexemeSourceFunc) Next() *Lexeme {
+	return f()
+}
+
+// ChannelSource returns a LexemeSource that reads lexemes from ch until it is
+// closed.
+func ChannelSource(ch <-chan *Lexeme) LexemeSource {
+	return LexemeSourceFunc(func() *Lexeme {
+		return <-ch
+	})
+}
+
+// BatchSource returns a LexemeSource that reads batches of lexemes from ch,
+// such as the channel returned by Lexer.LexBatches.
+func BatchSource(ch <-chan []*Lexeme) LexemeSource {
+	var batch []*Lexeme
+	return LexemeSourceFunc(func() *Lexeme {
+		for len(batch) == 0 {
+			var ok bool
+			if batch, ok = <-ch; !ok {
+				return nil
+			}
+		}
+		l := batch[0]
+		batch = batch[1:]
+		return l
+	})
+}
+
+// Transform is a middleware that transforms a stream of lexemes between a
+// Lexer and a Parser.
+type Transform func(LexemeSource) LexemeSource
+
+// Pipeline applies the transforms to src in order and returns the resulting
+// LexemeSource.
+func Pipeline(src LexemeSource, transforms ...Transform) LexemeSource {
+	for _, t := range transforms {
+		src = t(src)
+	}
+	return src
+}
+
+// Filter returns a Transform that only passes lexemes for which keep returns
+// true.
+func Filter(keep func(*Lexeme) bool) Transform {
+	return func(src LexemeSource) LexemeSource {
+		return LexemeSourceFunc(func() *Lexeme {
+			for {
+				l := src.Next()
+				if l == nil || keep(l) {
+					return l
+				}
+			}
+		})
+	}
+}
+
+// DropTypes returns a Transform that drops lexemes of the given types.
+func DropTypes(types ...LexemeType) Transform {
+	drop := typeSet(types)
+	return Filter(func(l *Lexeme) bool {
+		return !drop[l.Type]
+	})
+}
+
+// MergeTypes returns a Transform that merges consecutive lexemes of the same
+// type into a single lexeme if the type is one of the given types. The merged
+// lexeme has the position and leading trivia of the first lexeme and the
+// trailing trivia of the last lexeme. Trivia between the merged lexemes is
+// included in the value.
+func MergeTypes(types ...LexemeType) Transform {
+	merge := typeSet(types)
+	return func(src LexemeSource) LexemeSource {
+		var next *Lexeme
+		return LexemeSourceFunc(func() *Lexeme {
+			l := next
+			next = nil
+			if l == nil {
+				l = src.Next()
+			}
+			if l == nil || !merge[l.Type] {
+				return l
+			}
+
+			m := *l
+			var b strings.Builder
+			b.WriteString(m.Value)
+			for {
+				next = src.Next()
+				if next == nil || next.Type != m.Type {
+					break
+				}
+				b.WriteString(m.Trailing)
+				b.WriteString(next.Leading)
+				b.WriteString(next.Value)
+				m.Trailing = next.Trailing
+			}
+			m.Value = b.String()
+			return &m
+		})
+	}
+}
+
+// TerminatorOptions configures the Transform returned by InsertTerminators.
+type TerminatorOptions struct {
+	// Enders is the set of lexeme types that may end a statement. A
+	// terminator is inserted after a lexeme of one of these types if it is
+	// followed by a line break.
+	Enders []LexemeType
+
+	// Continuers is the set of lexeme types which continue a statement on
+	// the next line. No terminator is inserted before a lexeme of one of
+	// these types (e.g. a leading '.' in method chains).
+	Continuers []LexemeType
+
+	// AtEOF inserts a terminator at the end of the input if the last lexeme
+	// is one of Enders.
+	AtEOF bool
+
+	// Type is the type of the inserted terminator lexemes.
+	Type LexemeType
+
+	// Value is the value of the inserted terminator lexemes. It should be
+	// empty if the lexemes are expected to reproduce the input.
+	Value string
+}
+
+// InsertTerminators returns a Transform that inserts statement terminators
+// (e.g. semicolons) based on line breaks and the type of the previous lexeme,
+// as in Go and JavaScript. Inserted lexemes are positioned at the end of the
+// lexeme they follow.
+func InsertTerminators(opts TerminatorOptions) Transform {
+	enders := typeSet(opts.Enders)
+	continuers := typeSet(opts.Continuers)
+	return func(src LexemeSource) LexemeSource {
+		var prev, next *Lexeme
+		return LexemeSourceFunc(func() *Lexeme {
+			l := next
+			next = nil
+			if l == nil {
+				l = src.Next()
+			}
+
+			if prev != nil && enders[prev.Type] {
+				insert := false
+				if l == nil {
+					insert = opts.AtEOF
+				} else {
+					insert = l.Line > endLine(prev) && !continuers[l.Type]
+				}
+				if insert {
+					next = l
+					prev = terminator(prev, opts.Type, opts.Value)
+					return prev
+				}
+			}
+
+			if l != nil {
+				prev = l
+			}
+			return l
+		})
+	}
+}
+
+// terminator returns a new lexeme positioned at the end of l.
+func terminator(l *Lexeme, typ LexemeType, value string) *Lexeme {
+	t := &Lexeme{
+		Type:   typ,
+		Value:  value,
+		Pos:    l.Pos + utf8.RuneCountInString(l.Value),
+		Offset: l.Offset + len(l.Value),
+		Line:   endLine(l),
+		Column: l.Column + utf8.RuneCountInString(l.Value),
+		Source: l.Source,
+	}
+	if i := strings.LastIndexByte(l.Value, '\n'); i >= 0 {
+		t.Column = utf8.RuneCountInString(l.Value[i+1:])
+	}
+	return t
+}
+
+// endLine returns the line on which the value of l ends.
+func endLine(l *Lexeme) int {
+	return l.Line + strings.Count(l.Value, "\n")
+}
+
+func typeSet(types []LexemeType) map[LexemeType]bool {
+	s := make(map[LexemeType]bool, len(types))
+	for _, t := range types {
+		s[t] = true
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+const (
+	identType LexemeType = iota + 20
+	opType
+	semiType
+	textChunkType
+)
+
+// sliceSource returns a LexemeSource that returns the given lexemes.
+func sliceSource(lexemes ...*Lexeme) LexemeSource {
+	return LexemeSourceFunc(func() *Lexeme {
+		if len(lexemes) == 0 {
+			return nil
+		}
+		l := lexemes[0]
+		lexemes = lexemes[1:]
+		return l
+	})
+}
+
+func collect(src LexemeSource) []*Lexeme {
+	var lexemes []*Lexeme
+	for l := src.Next(); l != nil; l = src.Next() {
+		lexemes = append(lexemes, l)
+	}
+	return lexemes
+}
+
+func TestDropTypes(t *testing.T) {
+	t.Parallel()
+
+	src := sliceSource(
+		&Lexeme{Type: identType, Value: "a"},
+		&Lexeme{Type: opType, Value: "+"},
+		&Lexeme{Type: identType, Value: "b"},
+	)
+
+	got := collect(Pipeline(src, DropTypes(opType)))
+	want := []*Lexeme{
+		{Type: identType, Value: "a"},
+		{Type: identType, Value: "b"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeTypes(t *testing.T) {
+	t.Parallel()
+
+	src := sliceSource(
+		&Lexeme{Type: textChunkType, Value: "a", Leading: " "},
+		&Lexeme{Type: textChunkType, Value: "b", Pos: 3, Column: 3, Trailing: " "},
+		&Lexeme{Type: textChunkType, Value: "c", Pos: 5, Column: 5, Leading: "\n", Trailing: "\n"},
+		&Lexeme{Type: opType, Value: "+", Pos: 7, Line: 1},
+		&Lexeme{Type: textChunkType, Value: "d", Pos: 8, Line: 1, Column: 1},
+	)
+
+	got := collect(Pipeline(src, MergeTypes(textChunkType)))
+	want := []*Lexeme{
+		{Type: textChunkType, Value: "ab \nc", Leading: " ", Trailing: "\n"},
+		{Type: opType, Value: "+", Pos: 7, Line: 1},
+		{Type: textChunkType, Value: "d", Pos: 8, Line: 1, Column: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+}
+
+func TestInsertTerminators(t *testing.T) {
+	t.Parallel()
+
+	opts := TerminatorOptions{
+		Enders:     []LexemeType{identType},
+		Continuers: []LexemeType{opType},
+		AtEOF:      true,
+		Type:       semiType,
+		Value:      ";",
+	}
+
+	src := sliceSource(
+		// a
+		// b
+		// + é
+		// d;
+		&Lexeme{Type: identType, Value: "a"},
+		&Lexeme{Type: identType, Value: "b", Pos: 2, Offset: 2, Line: 1},
+		&Lexeme{Type: opType, Value: "+", Pos: 4, Offset: 4, Line: 2},
+		&Lexeme{Type: identType, Value: "é", Pos: 6, Offset: 6, Line: 2, Column: 2},
+		&Lexeme{Type: identType, Value: "d", Pos: 8, Offset: 9, Line: 3},
+		&Lexeme{Type: semiType, Value: ";", Pos: 9, Offset: 10, Line: 3, Column: 1},
+		&Lexeme{Type: identType, Value: "e", Pos: 11, Offset: 12, Line: 4},
+	)
+
+	got := collect(Pipeline(src, InsertTerminators(opts)))
+	want := []*Lexeme{
+		{Type: identType, Value: "a"},
+		{Type: semiType, Value: ";", Pos: 1, Offset: 1, Column: 1},
+		{Type: identType, Value: "b", Pos: 2, Offset: 2, Line: 1},
+		{Type: opType, Value: "+", Pos: 4, Offset: 4, Line: 2},
+		{Type: identType, Value: "é", Pos: 6, Offset: 6, Line: 2, Column: 2},
+		{Type: semiType, Value: ";", Pos: 7, Offset: 8, Line: 2, Column: 3},
+		{Type: identType, Value: "d", Pos: 8, Offset: 9, Line: 3},
+		{Type: semiType, Value: ";", Pos: 9, Offset: 10, Line: 3, Column: 1},
+		{Type: identType, Value: "e", Pos: 11, Offset: 12, Line: 4},
+		{Type: semiType, Value: ";", Pos: 12, Offset: 13, Line: 4, Column: 1},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+}
+
+func TestNewSourceParser(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("a b\nc")), &spaceWordState{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := Pipeline(ChannelSource(l.Lex(ctx)), InsertTerminators(TerminatorOptions{
+		Enders: []LexemeType{wordType},
+		Type:   semiType,
+		Value:  ";",
+	}))
+	got, err := NewSourceParser[string](src).Parse(ctx, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := newTree(
+		&Node[string]{Value: "a"},
+		&Node[string]{Value: "b"},
+		&Node[string]{Value: ";"},
+		&Node[string]{Value: "c"},
+	)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+}
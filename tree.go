@@ -0,0 +1,292 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+)
+
+var (
+	// ErrCycle means an operation would make a node a descendant of itself.
+	ErrCycle = errors.New("operation would create a cycle")
+
+	// ErrNotChild means a node is not a child of the expected parent.
+	ErrNotChild = errors.New("node is not a child")
+
+	// ErrIndexOutOfRange means a child index is out of range.
+	ErrIndexOutOfRange = errors.New("index out of range")
+)
+
+// Index returns the index of n in its parent's children or -1 if n has no
+// parent.
+func (n *Node[V]) Index() int {
+	if n.Parent == nil {
+		return -1
+	}
+	for i, c := range n.Parent.Children {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsAncestorOf reports whether n is an ancestor of m or m itself.
+func (n *Node[V]) IsAncestorOf(m *Node[V]) bool {
+	for ; m != nil; m = m.Parent {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Detach removes n from its parent's children. ErrMissingRequiredNode is
+// returned if n has no parent.
+func (n *Node[V]) Detach() error {
+	if n.Parent == nil {
+		return ErrMissingRequiredNode
+	}
+	n.detach()
+	return nil
+}
+
+// detach removes n from its parent, if it has one.
+func (n *Node[V]) detach() {
+	p := n.Parent
+	if p == nil {
+		return
+	}
+	children := p.Children[:0]
+	for _, c := range p.Children {
+		if c != n {
+			children = append(children, c)
+		}
+	}
+	// Clear the now unused tail so the removed node can be collected.
+	for i := len(children); i < len(p.Children); i++ {
+		p.Children[i] = nil
+	}
+	p.Children = children
+	n.Parent = nil
+}
+
+// Remove removes the child c from n. ErrNotChild is returned if c is not a
+// child of n.
+func (n *Node[V]) Remove(c *Node[V]) error {
+	if c == nil || c.Parent != n {
+		return ErrNotChild
+	}
+	return c.Detach()
+}
+
+// insert inserts the detached node c into n's children at index i.
+func (n *Node[V]) insert(i int, c *Node[V]) {
+	n.Children = append(n.Children, nil)
+	copy(n.Children[i+1:], n.Children[i:])
+	n.Children[i] = c
+	c.Parent = n
+}
+
+// InsertBefore inserts s into the tree as the previous sibling of n. s is
+// detached from its current parent first. ErrMissingRequiredNode is returned
+// if n has no parent and ErrCycle if s is n or an ancestor of n.
+func (n *Node[V]) InsertBefore(s *Node[V]) error {
+	if n.Parent == nil || s == nil {
+		return ErrMissingRequiredNode
+	}
+	if s.IsAncestorOf(n) {
+		return ErrCycle
+	}
+	s.detach()
+	n.Parent.insert(n.Index(), s)
+	return nil
+}
+
+// InsertAfter inserts s into the tree as the next sibling of n. s is detached
+// from its current parent first. ErrMissingRequiredNode is returned if n has
+// no parent and ErrCycle if s is n or an ancestor of n.
+func (n *Node[V]) InsertAfter(s *Node[V]) error {
+	if n.Parent == nil || s == nil {
+		return ErrMissingRequiredNode
+	}
+	if s.IsAncestorOf(n) {
+		return ErrCycle
+	}
+	s.detach()
+	n.Parent.insert(n.Index()+1, s)
+	return nil
+}
+
+// MoveTo moves n to be the child of p at index i. The index is relative to
+// p's children after n has been removed from its current parent. If i is
+// negative, n is appended to p's children. ErrMissingRequiredNode is returned
+// if p is nil, ErrCycle if n is p or an ancestor of p, and ErrIndexOutOfRange
+// if i is greater than the number of children.
+func (n *Node[V]) MoveTo(p *Node[V], i int) error {
+	if p == nil {
+		return ErrMissingRequiredNode
+	}
+	if n.IsAncestorOf(p) {
+		return ErrCycle
+	}
+	size := len(p.Children)
+	if n.Parent == p {
+		size--
+	}
+	if i < 0 {
+		i = size
+	}
+	if i > size {
+		return ErrIndexOutOfRange
+	}
+	n.detach()
+	p.insert(i, n)
+	return nil
+}
+
+// Wrap inserts a new node with value v in n's place in the tree and makes n
+// its only child. The new node has the position and trivia of n and is
+// returned.
+func (n *Node[V]) Wrap(v V) *Node[V] {
+	w := &Node[V]{
+		Parent:   n.Parent,
+		Value:    v,
+		Pos:      n.Pos,
+		Line:     n.Line,
+		Column:   n.Column,
+		Leading:  n.Leading,
+		Trailing: n.Trailing,
+		Source:   n.Source,
+	}
+	if i := n.Index(); i >= 0 {
+		n.Parent.Children[i] = w
+	}
+	w.Children = []*Node[V]{n}
+	n.Parent = w
+	return w
+}
+
+// Unwrap replaces n in its parent's children with n's children, splicing
+// them into its place. n is left detached with no children.
+// ErrMissingRequiredNode is returned if n has no parent.
+func (n *Node[V]) Unwrap() error {
+	p := n.Parent
+	if p == nil {
+		return ErrMissingRequiredNode
+	}
+	i := n.Index()
+
+	children := make([]*Node[V], 0, len(p.Children)-1+len(n.Children))
+	children = append(children, p.Children[:i]...)
+	for _, c := range n.Children {
+		c.Parent = p
+		children = append(children, c)
+	}
+	children = append(children, p.Children[i+1:]...)
+	p.Children = children
+
+	n.Parent = nil
+	n.Children = nil
+	return nil
+}
+
+// RotateLeft moves n to the position of its parent. The original parent
+// becomes the last child of n. ErrMissingRequiredNode is returned if n has
+// no parent.
+func (n *Node[V]) RotateLeft() error {
+	// op = original parent , gp = grand parent
+	op := n.Parent
+	if op == nil {
+		return ErrMissingRequiredNode
+	}
+	gp := op.Parent
+	i := op.Index()
+
+	n.detach()
+	op.Parent = nil
+	n.Children = append(n.Children, op)
+	op.Parent = n
+
+	n.Parent = gp
+	if gp != nil {
+		gp.Children[i] = n
+	}
+	return nil
+}
+
+// RotateRight moves the last child of n to the position of n. n becomes the
+// last child of its original last child, which is returned. It is the
+// inverse of RotateLeft when n was rotated from the last child position.
+// ErrMissingRequiredNode is returned if n has no children.
+func (n *Node[V]) RotateRight() (*Node[V], error) {
+	if len(n.Children) == 0 {
+		return nil, ErrMissingRequiredNode
+	}
+	c := n.Children[len(n.Children)-1]
+	if err := c.RotateLeft(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// AdoptPrevSibling moves the previous sibling of n to be the last child of n.
+// ErrMissingRequiredNode is returned if n has no previous sibling.
+func (n *Node[V]) AdoptPrevSibling() error {
+	i := n.Index()
+	if i <= 0 {
+		return ErrMissingRequiredNode
+	}
+	s := n.Parent.Children[i-1]
+	s.detach()
+	n.insert(len(n.Children), s)
+	return nil
+}
+
+// AdoptNextSibling moves the next sibling of n to be the last child of n.
+// ErrMissingRequiredNode is returned if n has no next sibling.
+func (n *Node[V]) AdoptNextSibling() error {
+	i := n.Index()
+	if i < 0 || i+1 >= len(n.Parent.Children) {
+		return ErrMissingRequiredNode
+	}
+	s := n.Parent.Children[i+1]
+	s.detach()
+	n.insert(len(n.Children), s)
+	return nil
+}
+
+// SwapWith swaps the positions of n and m in the tree. ErrMissingRequiredNode
+// is returned if either node has no parent and ErrCycle if one node is an
+// ancestor of the other.
+func (n *Node[V]) SwapWith(m *Node[V]) error {
+	if m == nil || n.Parent == nil || m.Parent == nil {
+		return ErrMissingRequiredNode
+	}
+	if n == m {
+		return nil
+	}
+	if n.IsAncestorOf(m) || m.IsAncestorOf(n) {
+		return ErrCycle
+	}
+
+	np, ni := n.Parent, n.Index()
+	mp, mi := m.Parent, m.Index()
+	np.Children[ni] = m
+	mp.Children[mi] = n
+	n.Parent = mp
+	m.Parent = np
+	return nil
+}
@@ -0,0 +1,456 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"errors"
+	"testing"
+)
+
+// find returns the first node in the tree rooted at n with value v.
+func find(n *Node[string], v string) *Node[string] {
+	for c := range PreOrder(n) {
+		if c.Value == v {
+			return c
+		}
+	}
+	return nil
+}
+
+// mutationTree returns the tree:
+//
+//	R
+//	├── A
+//	│   ├── D
+//	│   └── E
+//	├── B
+//	└── C
+func mutationTree() *Node[string] {
+	return addParent(&Node[string]{
+		Value: "R",
+		Children: []*Node[string]{
+			{
+				Value: "A",
+				Children: []*Node[string]{
+					{Value: "D"},
+					{Value: "E"},
+				},
+			},
+			{Value: "B"},
+			{Value: "C"},
+		},
+	})
+}
+
+// shape returns a compact representation of the tree rooted at n.
+func shape(n *Node[string]) string {
+	s := n.Value
+	if len(n.Children) > 0 {
+		s += "("
+		for i, c := range n.Children {
+			if i > 0 {
+				s += " "
+			}
+			s += shape(c)
+		}
+		s += ")"
+	}
+	return s
+}
+
+func TestNode_mutations(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		op   func(r *Node[string]) (*Node[string], error)
+		want string
+		err  error
+	}{
+		"insert before": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "B").InsertBefore(find(r, "E"))
+			},
+			want: "R(A(D) E B C)",
+		},
+		"insert after": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "C").InsertAfter(&Node[string]{Value: "F"})
+			},
+			want: "R(A(D E) B C F)",
+		},
+		"insert after self": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "B").InsertAfter(find(r, "B"))
+			},
+			err: ErrCycle,
+		},
+		"insert before root": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, r.InsertBefore(&Node[string]{Value: "F"})
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"insert ancestor": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "D").InsertAfter(find(r, "A"))
+			},
+			err: ErrCycle,
+		},
+		"detach": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").Detach()
+			},
+			want: "R(B C)",
+		},
+		"detach root": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, r.Detach()
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"remove": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").Remove(find(r, "E"))
+			},
+			want: "R(A(D) B C)",
+		},
+		"remove not child": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, r.Remove(find(r, "E"))
+			},
+			err: ErrNotChild,
+		},
+		"wrap": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				_ = find(r, "B").Wrap("W")
+				return r, nil
+			},
+			want: "R(A(D E) W(B) C)",
+		},
+		"wrap root": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r.Wrap("W"), nil
+			},
+			want: "W(R(A(D E) B C))",
+		},
+		"unwrap": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").Unwrap()
+			},
+			want: "R(D E B C)",
+		},
+		"unwrap root": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, r.Unwrap()
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"rotate left": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "D").RotateLeft()
+			},
+			want: "R(D(A(E)) B C)",
+		},
+		"rotate right": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				n, err := find(r, "A").RotateRight()
+				if n != nil && n.Value != "E" {
+					t.Errorf("RotateRight: want: E, got: %v", n.Value)
+				}
+				return r, err
+			},
+			want: "R(E(A(D)) B C)",
+		},
+		"rotate right root": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r.RotateRight()
+			},
+			want: "C(R(A(D E) B))",
+		},
+		"rotate right leaf": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				_, err := find(r, "B").RotateRight()
+				return r, err
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"adopt prev sibling": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "B").AdoptPrevSibling()
+			},
+			want: "R(B(A(D E)) C)",
+		},
+		"adopt prev sibling first": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").AdoptPrevSibling()
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"adopt next sibling": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").AdoptNextSibling()
+			},
+			want: "R(A(D E B) C)",
+		},
+		"adopt next sibling last": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "C").AdoptNextSibling()
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"move to": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "C").MoveTo(find(r, "A"), 1)
+			},
+			want: "R(A(D C E) B)",
+		},
+		"move to append": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").MoveTo(r, -1)
+			},
+			want: "R(B C A(D E))",
+		},
+		"move to same parent": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "C").MoveTo(r, 0)
+			},
+			want: "R(C A(D E) B)",
+		},
+		"move to out of range": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "C").MoveTo(r, 3)
+			},
+			err: ErrIndexOutOfRange,
+		},
+		"move to descendant": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "A").MoveTo(find(r, "D"), 0)
+			},
+			err: ErrCycle,
+		},
+		"swap": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "D").SwapWith(find(r, "C"))
+			},
+			want: "R(A(C E) B D)",
+		},
+		"swap ancestor": {
+			op: func(r *Node[string]) (*Node[string], error) {
+				return r, find(r, "D").SwapWith(find(r, "A"))
+			},
+			err: ErrCycle,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := tc.op(mutationTree())
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := shape(r), tc.want; got != want {
+				t.Errorf("unexpected tree: want: %s, got: %s", want, got)
+			}
+			if r.Parent != nil {
+				t.Errorf("root has parent: %v", r.Parent.Value)
+			}
+			checkParents(t, r)
+		})
+	}
+}
+
+func TestParser_mutations(t *testing.T) {
+	t.Parallel()
+
+	// Each case starts with the tree R(A(B) C) and B as the current node.
+	testCases := map[string]struct {
+		op   func(p *Parser[string]) error
+		want string
+		pos  string
+		err  error
+	}{
+		"wrap root": {
+			op: func(p *Parser[string]) error {
+				_ = p.Wrap(p.Root(), "W")
+				return nil
+			},
+			want: "W(R(A(B(X)) C))",
+			pos:  "B",
+		},
+		"wrap current": {
+			op: func(p *Parser[string]) error {
+				_ = p.Wrap(p.Pos(), "W")
+				return nil
+			},
+			want: "R(A(W(B(X))) C)",
+			pos:  "B",
+		},
+		"detach ancestor": {
+			op: func(p *Parser[string]) error {
+				return p.Detach(find(p.Root(), "A"))
+			},
+			want: "R(C X)",
+			pos:  "R",
+		},
+		"detach root": {
+			op: func(p *Parser[string]) error {
+				return p.Detach(p.Root())
+			},
+			err: ErrMissingRequiredNode,
+		},
+		"unwrap current": {
+			op: func(p *Parser[string]) error {
+				return p.Unwrap(p.Pos())
+			},
+			want: "R(A(X) C)",
+			pos:  "A",
+		},
+		"unwrap ancestor": {
+			op: func(p *Parser[string]) error {
+				return p.Unwrap(find(p.Root(), "A"))
+			},
+			want: "R(B(X) C)",
+			pos:  "B",
+		},
+		"move current": {
+			op: func(p *Parser[string]) error {
+				return p.MoveTo(p.Pos(), find(p.Root(), "C"), 0)
+			},
+			want: "R(A C(B(X)))",
+			pos:  "B",
+		},
+		"move out of tree": {
+			op: func(p *Parser[string]) error {
+				return p.MoveTo(find(p.Root(), "A"), &Node[string]{Value: "D"}, 0)
+			},
+			want: "R(C X)",
+			pos:  "R",
+		},
+		"insert before": {
+			op: func(p *Parser[string]) error {
+				return p.InsertBefore(find(p.Root(), "A"), p.Pos())
+			},
+			want: "R(B(X) A C)",
+			pos:  "B",
+		},
+		"insert after": {
+			op: func(p *Parser[string]) error {
+				return p.InsertAfter(find(p.Root(), "C"), find(p.Root(), "A"))
+			},
+			want: "R(C A(B(X)))",
+			pos:  "B",
+		},
+		"swap": {
+			op: func(p *Parser[string]) error {
+				return p.SwapWith(p.Pos(), find(p.Root(), "C"))
+			},
+			want: "R(A(C) B(X))",
+			pos:  "B",
+		},
+		"rotate right": {
+			op: func(p *Parser[string]) error {
+				_ = p.Climb()
+				n, err := p.RotateRight()
+				if n != nil && n.Value != "B" {
+					t.Errorf("RotateRight: want: B, got: %v", n.Value)
+				}
+				return err
+			},
+			want: "R(B(A(X)) C)",
+			pos:  "A",
+		},
+		"rotate right root": {
+			op: func(p *Parser[string]) error {
+				_ = p.Climb()
+				_ = p.Climb()
+				_, err := p.RotateRight()
+				return err
+			},
+			want: "C(R(A(B) X))",
+			pos:  "R",
+		},
+		"adopt next sibling": {
+			op: func(p *Parser[string]) error {
+				_ = p.Climb()
+				_, err := p.AdoptNextSibling()
+				return err
+			},
+			want: "R(A(B C X))",
+			pos:  "A",
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := NewParser[string](nil)
+			_ = p.Replace("R")
+			a := p.Push("A")
+			_ = p.Push("B")
+			if err := p.InsertAfter(a, &Node[string]{Value: "C"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err := tc.op(p)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := p.Pos().Value, tc.pos; got != want {
+				t.Errorf("Pos: want: %v, got: %v", want, got)
+			}
+			if got, want := p.depth, p.nodeDepth(p.Pos()); got != want {
+				t.Errorf("depth: want: %d, got: %d", want, got)
+			}
+
+			// Nodes pushed after the operation are added to the tree.
+			_ = p.Push("X")
+			if got, want := shape(p.Root()), tc.want; got != want {
+				t.Errorf("unexpected tree: want: %s, got: %s", want, got)
+			}
+			if p.Root().Parent != nil {
+				t.Errorf("root has parent: %v", p.Root().Parent.Value)
+			}
+			checkParents(t, p.Root())
+		})
+	}
+}
+
+func TestParser_RotateLeftRoot(t *testing.T) {
+	t.Parallel()
+
+	p := NewParser[string](nil)
+	a := p.Push("A")
+
+	n, err := p.RotateLeft()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := p.Root(), a; got != want || n != a {
+		t.Errorf("Root: want: %v, got: %v", want.Value, got.Value)
+	}
+	checkParents(t, p.Root())
+}
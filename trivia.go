@@ -0,0 +1,67 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotLossless indicates that a lexeme stream does not reproduce its input.
+var ErrNotLossless = errors.New("lexemes do not reproduce input")
+
+// Text returns the source text of the lexemes by concatenating the Leading
+// trivia, Value and Trailing trivia of each lexeme.
+func Text(lexemes []*Lexeme) string {
+	var b strings.Builder
+	for _, l := range lexemes {
+		b.WriteString(l.Leading)
+		b.WriteString(l.Value)
+		b.WriteString(l.Trailing)
+	}
+	return b.String()
+}
+
+// CheckRoundTrip lexes input starting at initState with trivia preserved and
+// checks that the emitted lexemes reproduce the input byte for byte. If they
+// do not, an error wrapping ErrNotLossless is returned with the byte offset of
+// the first difference. Lexing errors are returned as is.
+func CheckRoundTrip(ctx context.Context, input string, initState State) error {
+	l := NewLexer(NewStringReader(input), initState, WithTrivia(true))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lexemes []*Lexeme
+	for lexeme := range l.Lex(ctx) {
+		lexemes = append(lexemes, lexeme)
+	}
+	if err := l.Err(); err != nil {
+		return err
+	}
+
+	got := Text(lexemes)
+	if got == input {
+		return nil
+	}
+
+	i := 0
+	for i < len(got) && i < len(input) && got[i] == input[i] {
+		i++
+	}
+	return fmt.Errorf("%w: first difference at byte offset %d", ErrNotLossless, i)
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/ianlewis/runeio"
+)
+
+func TestLexer_PreserveTrivia(t *testing.T) {
+	t.Parallel()
+
+	input := "  a b // c\n\n/* d */ e  \n"
+	l := NewLexer(runeio.NewReader(strings.NewReader(input)), SkipComments(CComments, &spaceWordState{}))
+	l.PreserveTrivia(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []*Lexeme
+	for lexeme := range l.Lex(ctx) {
+		got = append(got, lexeme)
+	}
+	if err := l.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []*Lexeme{
+		{Type: wordType, Value: "a", Pos: 2, Offset: 2, Column: 2, Leading: "  ", Trailing: " "},
+		{Type: wordType, Value: "b", Pos: 4, Offset: 4, Column: 4, Trailing: " // c\n"},
+		{Type: wordType, Value: "e", Pos: 20, Offset: 20, Line: 2, Column: 8, Leading: "\n/* d */ ", Trailing: "  \n"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected lexemes (-want +got):\n%s", diff)
+	}
+
+	if got, want := Text(got), input; got != want {
+		t.Errorf("Text: want: %q, got: %q", want, got)
+	}
+}
+
+func TestParser_trivia(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(runeio.NewReader(strings.NewReader("Hello \n  World!\n")), &spaceWordState{})
+	l.PreserveTrivia(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewParser[string](l.Lex(ctx))
+	got, err := p.Parse(ctx, parseWord)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := newTree(
+		&Node[string]{
+			Value:    "Hello",
+			Trailing: " \n",
+		},
+		&Node[string]{
+			Value:    "World!",
+			Leading:  "  ",
+			Trailing: "\n",
+		},
+	)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+}
+
+// dropState consumes input without emitting or discarding it.
+func dropState(_ context.Context, l *Lexer) (State, error) {
+	if _, err := l.Advance(1); err != nil {
+		return nil, err
+	}
+	l.Emit(&Lexeme{Value: "x"})
+	return nil, nil
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		input string
+		state State
+		err   error
+	}{
+		"words": {
+			input: "Hello\n  World!  ",
+			state: &spaceWordState{},
+		},
+		"comments": {
+			input: "/* a */ b // c\n/* d\n */\n",
+			state: SkipComments(CComments, &spaceWordState{}),
+		},
+		"trivia only": {
+			input: "  \n\t ",
+			state: &spaceWordState{},
+		},
+		"empty": {
+			input: "",
+			state: &spaceWordState{},
+		},
+		"not lossless": {
+			input: "ab",
+			state: StateFn(dropState),
+			err:   ErrNotLossless,
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := CheckRoundTrip(context.Background(), tc.input, tc.state); !errors.Is(err, tc.err) {
+				t.Errorf("unexpected error: want: %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+// parseSkipSemicolons is like parseWord but doesn't create nodes for ";".
+func parseSkipSemicolons(_ context.Context, p *Parser[string]) (ParseFn[string], error) {
+	l := p.Next()
+	if l == nil {
+		return nil, nil
+	}
+	if l.Value != ";" {
+		p.Node(l.Value)
+	}
+	return parseSkipSemicolons, nil
+}
+
+func TestParser_skippedTrivia(t *testing.T) {
+	t.Parallel()
+
+	l := NewLexer(NewStringReader("a ; /* x */ ;\n b"), SkipComments(CComments, &spaceWordState{}),
+		WithTrivia(true),
+	)
+	p := NewParser[string](l.Lex(context.Background()))
+	got, err := p.Parse(context.Background(), parseSkipSemicolons)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := newTree(
+		&Node[string]{
+			Value:    "a",
+			Trailing: " ",
+		},
+		&Node[string]{
+			Value:   "b",
+			Leading: " /* x */ \n ",
+		},
+	)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected tree (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"iter"
+)
+
+// WalkAction controls how Walk proceeds after visiting a node.
+type WalkAction int
+
+const (
+	// WalkContinue continues the traversal.
+	WalkContinue WalkAction = iota
+
+	// WalkSkipChildren skips the children of the node being entered. The
+	// node's Leave method is still called. It is equivalent to WalkContinue
+	// when returned from Leave.
+	WalkSkipChildren
+
+	// WalkStop stops the traversal.
+	WalkStop
+)
+
+// Visitor is called by Walk for each node in a tree.
+type Visitor[V comparable] interface {
+	// Enter is called before the node's children are visited.
+	Enter(n *Node[V]) WalkAction
+
+	// Leave is called after the node's children are visited.
+	Leave(n *Node[V]) WalkAction
+}
+
+// VisitorFuncs implements Visitor using functions. Nil functions are treated
+// as returning WalkContinue.
+type VisitorFuncs[V comparable] struct {
+	EnterFunc func(n *Node[V]) WalkAction
+	LeaveFunc func(n *Node[V]) WalkAction
+}
+
+// Enter implements Visitor.
+func (v VisitorFuncs[V]) Enter(n *Node[V]) WalkAction {
+	if v.EnterFunc == nil {
+		return WalkContinue
+	}
+	return v.EnterFunc(n)
+}
+
+// Leave implements Visitor.
+func (v VisitorFuncs[V]) Leave(n *Node[V]) WalkAction {
+	if v.LeaveFunc == nil {
+		return WalkContinue
+	}
+	return v.LeaveFunc(n)
+}
+
+// walkFrame is a node on the Walk stack along with the index of the next
+// child to visit.
+type walkFrame[V comparable] struct {
+	node  *Node[V]
+	child int
+}
+
+// Walk traverses the tree rooted at n in depth-first order calling v.Enter
+// before visiting a node's children and v.Leave afterwards. Walk is
+// iterative and so can be used on arbitrarily deep trees. The tree should not
+// be modified during the traversal except for the children of the node
+// currently being entered.
+func Walk[V comparable](n *Node[V], v Visitor[V]) {
+	if n == nil {
+		return
+	}
+
+	var stack []walkFrame[V]
+	enter := func(n *Node[V]) bool {
+		switch v.Enter(n) {
+		case WalkStop:
+			return false
+		case WalkSkipChildren:
+			stack = append(stack, walkFrame[V]{node: n, child: len(n.Children)})
+		case WalkContinue:
+			stack = append(stack, walkFrame[V]{node: n})
+		}
+		return true
+	}
+
+	if !enter(n) {
+		return
+	}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child < len(top.node.Children) {
+			c := top.node.Children[top.child]
+			top.child++
+			if c == nil {
+				continue
+			}
+			if !enter(c) {
+				return
+			}
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+		if v.Leave(top.node) == WalkStop {
+			return
+		}
+	}
+}
+
+// Inspect traverses the tree rooted at n in depth-first order, in the manner
+// of go/ast.Inspect. It calls f(n); if f returns true, Inspect invokes f for
+// each of the children of n, followed by a call of f(nil).
+func Inspect[V comparable](n *Node[V], f func(*Node[V]) bool) {
+	Walk[V](n, &inspector[V]{f: f})
+}
+
+type inspector[V comparable] struct {
+	f func(*Node[V]) bool
+
+	// entered records whether f returned true for each node on the current
+	// path.
+	entered []bool
+}
+
+// Enter implements Visitor.
+func (in *inspector[V]) Enter(n *Node[V]) WalkAction {
+	ok := in.f(n)
+	in.entered = append(in.entered, ok)
+	if !ok {
+		return WalkSkipChildren
+	}
+	return WalkContinue
+}
+
+// Leave implements Visitor.
+func (in *inspector[V]) Leave(*Node[V]) WalkAction {
+	ok := in.entered[len(in.entered)-1]
+	in.entered = in.entered[:len(in.entered)-1]
+	if ok {
+		in.f(nil)
+	}
+	return WalkContinue
+}
+
+// PreOrder returns an iterator over the tree rooted at n in depth-first
+// pre-order.
+func PreOrder[V comparable](n *Node[V]) iter.Seq[*Node[V]] {
+	return func(yield func(*Node[V]) bool) {
+		if n == nil {
+			return
+		}
+		stack := []*Node[V]{n}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !yield(top) {
+				return
+			}
+			for i := len(top.Children) - 1; i >= 0; i-- {
+				if top.Children[i] != nil {
+					stack = append(stack, top.Children[i])
+				}
+			}
+		}
+	}
+}
+
+// PostOrder returns an iterator over the tree rooted at n in depth-first
+// post-order.
+func PostOrder[V comparable](n *Node[V]) iter.Seq[*Node[V]] {
+	return func(yield func(*Node[V]) bool) {
+		Walk[V](n, VisitorFuncs[V]{
+			LeaveFunc: func(n *Node[V]) WalkAction {
+				if !yield(n) {
+					return WalkStop
+				}
+				return WalkContinue
+			},
+		})
+	}
+}
+
+// BreadthFirst returns an iterator over the tree rooted at n in breadth-first
+// order.
+func BreadthFirst[V comparable](n *Node[V]) iter.Seq[*Node[V]] {
+	return func(yield func(*Node[V]) bool) {
+		if n == nil {
+			return
+		}
+		queue := []*Node[V]{n}
+		for len(queue) > 0 {
+			head := queue[0]
+			queue[0] = nil
+			queue = queue[1:]
+			if !yield(head) {
+				return
+			}
+			for _, c := range head.Children {
+				if c != nil {
+					queue = append(queue, c)
+				}
+			}
+		}
+	}
+}
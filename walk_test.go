@@ -0,0 +1,214 @@
+// Copyright 2024 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lexparse
+
+import (
+	"iter"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// walkTree returns the tree:
+//
+//	A
+//	├── B
+//	│   ├── D
+//	│   └── E
+//	└── C
+//	    └── F
+func walkTree() *Node[string] {
+	return addParent(&Node[string]{
+		Value: "A",
+		Children: []*Node[string]{
+			{
+				Value: "B",
+				Children: []*Node[string]{
+					{Value: "D"},
+					{Value: "E"},
+				},
+			},
+			{
+				Value: "C",
+				Children: []*Node[string]{
+					{Value: "F"},
+				},
+			},
+		},
+	})
+}
+
+func values(seq iter.Seq[*Node[string]]) []string {
+	var vals []string
+	for n := range seq {
+		vals = append(vals, n.Value)
+	}
+	return vals
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		enter map[string]WalkAction
+		leave map[string]WalkAction
+		want  []string
+	}{
+		"all": {
+			want: []string{
+				"+A", "+B", "+D", "-D", "+E", "-E", "-B", "+C", "+F", "-F", "-C", "-A",
+			},
+		},
+		"skip children": {
+			enter: map[string]WalkAction{"B": WalkSkipChildren},
+			want: []string{
+				"+A", "+B", "-B", "+C", "+F", "-F", "-C", "-A",
+			},
+		},
+		"stop on enter": {
+			enter: map[string]WalkAction{"E": WalkStop},
+			want: []string{
+				"+A", "+B", "+D", "-D", "+E",
+			},
+		},
+		"stop on leave": {
+			leave: map[string]WalkAction{"B": WalkStop},
+			want: []string{
+				"+A", "+B", "+D", "-D", "+E", "-E", "-B",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			Walk[string](walkTree(), VisitorFuncs[string]{
+				EnterFunc: func(n *Node[string]) WalkAction {
+					got = append(got, "+"+n.Value)
+					return tc.enter[n.Value]
+				},
+				LeaveFunc: func(n *Node[string]) WalkAction {
+					got = append(got, "-"+n.Value)
+					return tc.leave[n.Value]
+				},
+			})
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("unexpected visits (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	Inspect(walkTree(), func(n *Node[string]) bool {
+		if n == nil {
+			got = append(got, "nil")
+			return true
+		}
+		got = append(got, n.Value)
+		return n.Value != "B"
+	})
+
+	want := []string{"A", "B", "C", "F", "nil", "nil", "nil"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected visits (-want +got):\n%s", diff)
+	}
+}
+
+func TestIterators(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		seq  iter.Seq[*Node[string]]
+		want []string
+	}{
+		"pre-order": {
+			seq:  PreOrder(walkTree()),
+			want: []string{"A", "B", "D", "E", "C", "F"},
+		},
+		"post-order": {
+			seq:  PostOrder(walkTree()),
+			want: []string{"D", "E", "B", "F", "C", "A"},
+		},
+		"breadth-first": {
+			seq:  BreadthFirst(walkTree()),
+			want: []string{"A", "B", "C", "D", "E", "F"},
+		},
+		"nil": {
+			seq: PreOrder[string](nil),
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, values(tc.seq)); diff != "" {
+				t.Errorf("unexpected order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestIterators_break(t *testing.T) {
+	t.Parallel()
+
+	for name, seq := range map[string]iter.Seq[*Node[string]]{
+		"pre-order":     PreOrder(walkTree()),
+		"post-order":    PostOrder(walkTree()),
+		"breadth-first": BreadthFirst(walkTree()),
+	} {
+		var count int
+		for range seq {
+			count++
+			if count == 2 {
+				break
+			}
+		}
+		if count != 2 {
+			t.Errorf("%s: unexpected count: %d", name, count)
+		}
+	}
+}
+
+func TestWalk_deep(t *testing.T) {
+	t.Parallel()
+
+	const depth = 1_000_000
+
+	root := &Node[string]{}
+	n := root
+	for i := 0; i < depth; i++ {
+		c := &Node[string]{Parent: n}
+		n.Children = []*Node[string]{c}
+		n = c
+	}
+
+	var count int
+	for range PostOrder(root) {
+		count++
+	}
+	if got, want := count, depth+1; got != want {
+		t.Errorf("PostOrder: want: %d, got: %d", want, got)
+	}
+}